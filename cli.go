@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/renner/file-importer/importer"
+)
+
+// globalOptions are accepted by every command, before or after its name, including the
+// commands that ignore them: only import, plan and verify run workers and only import
+// draws the progress spinner
+type globalOptions struct {
+	Workers int
+	Quiet   bool
+}
+
+// command is a single CLI subcommand. Argument errors are returned as usageError so they
+// exit with status 2, other errors exit with status 1.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) error
+}
+
+// usageError wraps an error caused by invalid command line arguments
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// Commands in the order they are listed by 'help'
+var commands []command
+
+func init() {
+	commands = []command{
+		{"import", "--from <source> --to <destination> [options]", "Copy or move files into date-based folders", runImportCommand},
+		{"plan", "--from <source> --to <destination> [options]", "Show what import would do without writing anything", runPlanCommand},
+		{"verify", "--to <destination> [run-id ...]", "Check that imported files are still present and unchanged", runVerifyCommand},
+		{"undo", "<run-id> --to <destination>", "Revert an import run from its manifest", runUndoCommand},
+		{"reorganize", "--to <destination> --layout <template> [options]", "Re-sort an existing library into a new layout", runReorganizeCommand},
+		{"stats", "--to <destination>", "Summarize the files in a library", runStatsCommand},
+		{"inspect", "[options] <file or folder> ...", "Show the timestamp candidates of files and where they would be imported", runInspectCommand},
+	}
+}
+
+// Create the flag set for a command with the global flags already registered. Its usage
+// output is the per-command help.
+func newCommandFlagSet(name string, global *globalOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("file-importer "+name, flag.ContinueOnError)
+	fs.IntVar(&global.Workers, "workers", importer.DefaultWorkers, "Maximum number of concurrent workers (import, plan and verify)")
+	fs.BoolVar(&global.Quiet, "quiet", false, "Do not draw the progress spinner (import)")
+	fs.Usage = func() {
+		out := fs.Output()
+		if cmd, ok := findCommand(name); ok {
+			fmt.Fprintf(out, "Usage: file-importer %s %s\n\n%s.\n\nOptions:\n", cmd.name, cmd.args, cmd.summary)
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintf(out, "Usage: file-importer <command> [options]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\nRun 'file-importer help <command>' for the options of a command.\n")
+	fmt.Fprintf(out, "Without a command the arguments are passed to 'import'.\n")
+}
+
+// Run the CLI and return the process exit status
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	name := "import"
+	var global []string
+	if lead, rest := splitGlobalFlags(args); len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		name, global, args = rest[0], lead, rest[1:]
+	}
+	if name == "help" {
+		if len(args) == 0 {
+			printUsage(stdout)
+			return 0
+		}
+		if _, ok := findCommand(args[0]); !ok {
+			fmt.Fprintf(stderr, "Error: unknown command %q\n", args[0])
+			return 2
+		}
+		name, args = args[0], []string{"-h"}
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(stderr, "Error: unknown command %q\n\n", name)
+		printUsage(stderr)
+		return 2
+	}
+
+	err := cmd.run(slices.Concat(global, args), stdout, stderr)
+	var usage usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usage):
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	fmt.Fprintf(stderr, "Error: %v\n", err)
+	if errors.Is(err, context.Canceled) {
+		return 130
+	}
+	return 1
+}
+
+// Split the global flags in front of the command name off args, so that
+// 'file-importer --workers 4 verify ...' hands them to verify. rest starts at the first
+// argument that is not a global flag.
+func splitGlobalFlags(args []string) (global, rest []string) {
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			return args[:i], args[i:]
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		switch {
+		case name == "quiet":
+		case name == "workers" && !hasValue:
+			i++
+		case name == "workers":
+		default:
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+func runImportCommand(args []string, stdout, stderr io.Writer) error {
+	cfg, global, err := parseImportFlags("import", args)
+	if err != nil {
+		return usageError{err}
+	}
+	cfg.Output = stdout
+	if !global.Quiet {
+		cfg.Progress = stderr
+	}
+	return runImporter(cfg)
+}
+
+func runPlanCommand(args []string, stdout, stderr io.Writer) error {
+	cfg, err := parsePlanFlags(args)
+	if err != nil {
+		return usageError{err}
+	}
+	cfg.Output = stdout
+	return runImporter(cfg)
+}
+
+// Parse the arguments of 'plan': the import options, always as a dry run
+func parsePlanFlags(args []string) (importer.Options, error) {
+	cfg, _, err := parseImportFlags("plan", args)
+	if err != nil {
+		return importer.Options{}, err
+	}
+	cfg.DryRun = true
+	return cfg, nil
+}
+
+// Run an import that stops handing out files on SIGINT or SIGTERM. A second signal kills
+// the process.
+func runImporter(cfg importer.Options) error {
+	im, err := importer.New(cfg)
+	if err != nil {
+		return usageError{err}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	_, err = im.Run(ctx)
+	return err
+}
+
+func runUndoCommand(args []string, stdout, stderr io.Writer) error {
+	cfg, err := parseUndoFlags(args)
+	if err != nil {
+		return usageError{err}
+	}
+	_, err = importer.Undo(cfg, stdout)
+	return err
+}
+
+func runVerifyCommand(args []string, stdout, stderr io.Writer) error {
+	cfg, err := parseVerifyFlags(args)
+	if err != nil {
+		return usageError{err}
+	}
+	_, err = importer.Verify(cfg, stdout)
+	return err
+}
+
+func runReorganizeCommand(args []string, stdout, stderr io.Writer) error {
+	cfg, err := parseReorganizeFlags(args)
+	if err != nil {
+		return usageError{err}
+	}
+	_, err = importer.Reorganize(cfg, stdout)
+	return err
+}
+
+func runStatsCommand(args []string, stdout, stderr io.Writer) error {
+	cfg, err := parseStatsFlags(args)
+	if err != nil {
+		return usageError{err}
+	}
+	_, err = importer.Stats(cfg, stdout)
+	return err
+}
+
+func runInspectCommand(args []string, stdout, stderr io.Writer) error {
+	cfg, err := parseInspectFlags(args)
+	if err != nil {
+		return usageError{err}
+	}
+	return importer.Inspect(cfg, stdout)
+}
+
+func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+}
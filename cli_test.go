@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCLIPrintsCommandHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"help"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	for _, cmd := range commands {
+		if !strings.Contains(stdout.String(), cmd.name) {
+			t.Fatalf("expected %q in command list, got:\n%s", cmd.name, stdout.String())
+		}
+	}
+
+	fs := newCommandFlagSet("reorganize", &globalOptions{})
+	var help bytes.Buffer
+	fs.SetOutput(&help)
+	fs.Usage()
+	if !strings.Contains(help.String(), "Usage: file-importer reorganize") || !strings.Contains(help.String(), "-workers") {
+		t.Fatalf("expected reorganize help with global flags, got:\n%s", help.String())
+	}
+}
+
+func TestRunCLIRejectsUnknownCommandAndBadFlags(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"frobnicate"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("expected exit code 2 for unknown command, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "frobnicate"`) {
+		t.Fatalf("expected unknown command error, got:\n%s", stderr.String())
+	}
+
+	stderr.Reset()
+	if code := runCLI([]string{"import", "--from", "/src"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("expected exit code 2 for missing --to, got %d", code)
+	}
+}
+
+func TestRunCLIWithoutCommandImports(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	path := filepath.Join(from, "a.jpg")
+	if err := os.WriteFile(path, []byte("a"), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("set mtime failed: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"--from", from, "--to", to, "--fast", "--quiet"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstderr:\n%s", code, stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(to, "2024-05-06-jpg", "a.jpg")); err != nil {
+		t.Fatalf("expected imported file: %v", err)
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("expected no progress output with --quiet, got:\n%s", stderr.String())
+	}
+}
+
+func TestParseCommandFlags(t *testing.T) {
+	cfg, err := parsePlanFlags([]string{"--from", "/src", "--to", "/dst", "--workers", "4"})
+	if err != nil {
+		t.Fatalf("parsePlanFlags returned error: %v", err)
+	}
+	if !cfg.DryRun || cfg.MaxWorkers != 4 {
+		t.Fatalf("expected dry run with 4 workers, got: %+v", cfg)
+	}
+
+	verify, err := parseVerifyFlags([]string{"run-a", "--to", "/dst", "run-b"})
+	if err != nil {
+		t.Fatalf("parseVerifyFlags returned error: %v", err)
+	}
+	if verify.To != "/dst" || strings.Join(verify.RunIDs, ",") != "run-a,run-b" {
+		t.Fatalf("unexpected verify config: %+v", verify)
+	}
+
+	if _, err := parseReorganizeFlags([]string{"--to", "/dst", "--layout", "{nope}"}); err == nil || !strings.Contains(err.Error(), "invalid --layout") {
+		t.Fatalf("expected layout validation error, got: %v", err)
+	}
+	if _, err := parseStatsFlags([]string{}); err == nil || !strings.Contains(err.Error(), "--to") {
+		t.Fatalf("expected missing --to error, got: %v", err)
+	}
+	// Commands that run no workers and draw no spinner still accept the global flags
+	if _, err := parseStatsFlags([]string{"--to", "/dst", "--workers", "2", "--quiet"}); err != nil {
+		t.Fatalf("expected stats to accept the global flags, got: %v", err)
+	}
+}
+
+func TestRunCLIAcceptsGlobalFlagsBeforeTheCommand(t *testing.T) {
+	global, rest := splitGlobalFlags([]string{"--workers", "4", "-quiet", "--workers=2", "verify", "--to", "/dst"})
+	if strings.Join(global, " ") != "--workers 4 -quiet --workers=2" || strings.Join(rest, " ") != "verify --to /dst" {
+		t.Fatalf("unexpected split: %q %q", global, rest)
+	}
+
+	// The flags reach the command: verify rejects 0 workers, stats ignores them
+	lib := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"--workers", "0", "verify", "--to", lib}, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "--workers must be >= 1") {
+		t.Fatalf("expected verify to reject --workers 0, got %d\nstderr:\n%s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := runCLI([]string{"--workers", "4", "--quiet", "stats", "--to", lib}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstderr:\n%s", code, stderr.String())
+	}
+}
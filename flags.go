@@ -0,0 +1,312 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/renner/file-importer/importer"
+)
+
+func parseFlags(args []string) (importer.Options, error) {
+	cfg, _, err := parseImportFlags("import", args)
+	return cfg, err
+}
+
+// Parse the import options for the named command ('import' or 'plan')
+func parseImportFlags(name string, args []string) (importer.Options, globalOptions, error) {
+	var cfg importer.Options
+	var startStr, endStr string
+	var global globalOptions
+	fs := newCommandFlagSet(name, &global)
+	fs.StringVar(&cfg.From, "from", "", "Source path")
+	fs.StringVar(&cfg.To, "to", "", "Destination path")
+	fs.StringVar(&cfg.Filter, "filter", "", "Optional file type filter")
+	fs.StringVar(&startStr, "start", "", "Start date (format YYYY-MM-DD), in the --folder-tz zone")
+	fs.StringVar(&endStr, "end", "", "End date (format YYYY-MM-DD), in the --folder-tz zone")
+	fs.BoolVar(&cfg.UseModTime, "fast", false, "Use filesystem modtime instead of parsing EXIF/CR3 to massively increase speed")
+	fs.BoolVar(&cfg.Recursive, "recursive", false, "Descend into subdirectories of the source path (e.g. DCIM/100CANON)")
+	fs.IntVar(&cfg.MaxDepth, "max-depth", 0, "Maximum directory depth below the source path when --recursive is set (0 = unlimited)")
+	fs.StringVar(&cfg.Layout, "layout", importer.DefaultLayout, "Destination folder template, e.g. {yyyy}/{mm}/{date} or {camera}/{date}")
+	fs.StringVar(&cfg.Rename, "rename", "", "Optional file name template, e.g. {yyyyMMdd}_{HHmmss}_{seq:4}_{orig}")
+	fs.BoolVar(&cfg.KeepBackup, "keep-backups", false, "Keep files replaced by --on-conflict overwrite so 'undo' can restore them")
+	fs.BoolVar(&cfg.Resume, "resume", false, "Skip files the import journal records as finished and unchanged (same path, size and mtime)")
+	fs.StringVar(&cfg.Report, "report", "", "Write a JSON report with the outcome of every file to this path")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "Print what would be imported where without creating any folders or files")
+	fs.BoolVar(&cfg.Move, "move", false, "Delete each source file after a verified copy (never when the destination collided)")
+	fs.BoolVar(&cfg.Verify, "verify", false, "Re-read every copied file and compare its checksum with the source")
+	fs.StringVar(&cfg.VerifyHash, "verify-hash", importer.DefaultHashAlgorithm, "Checksum algorithm for --verify: sha256, sha512, sha1, md5 or crc32")
+	fs.BoolVar(&cfg.Dedupe, "dedupe", false, "Skip files whose content already exists anywhere below the destination path")
+	fs.StringVar(&cfg.OnConflict, "on-conflict", importer.DefaultConflictPolicy, "What to do when the destination file exists: skip, overwrite, rename, skip-if-identical or fail")
+	fs.StringVar(&cfg.Mtime, "mtime", importer.MtimeSource, "Set the mtime of copied files to that of the 'source' or to the 'capture' time, including sub-seconds")
+	loadResolverOptions := addResolverFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return importer.Options{}, global, err
+	}
+	if len(fs.Args()) > 0 {
+		return importer.Options{}, global, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	cfg.MaxWorkers = global.Workers
+	timeOpts, err := loadResolverOptions()
+	if err != nil {
+		return importer.Options{}, global, err
+	}
+	cfg.TimeOptions = timeOpts
+	window := time.Local
+	if cfg.FolderTimeZone != nil {
+		window = cfg.FolderTimeZone
+	}
+	if startStr != "" {
+		startDay, err := time.ParseInLocation("2006-01-02", startStr, window)
+		if err != nil {
+			return importer.Options{}, global, fmt.Errorf("invalid start date format (use YYYY-MM-DD): %w", err)
+		}
+		cfg.Start = startDay
+	}
+	if endStr != "" {
+		endDay, err := time.ParseInLocation("2006-01-02", endStr, window)
+		if err != nil {
+			return importer.Options{}, global, fmt.Errorf("invalid end date format (use YYYY-MM-DD): %w", err)
+		}
+		cfg.End = endDay.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	} else {
+		cfg.End = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	}
+	if cfg.From == "" || cfg.To == "" {
+		return importer.Options{}, global, fmt.Errorf("need source and target directory (use '--from' and '--to')")
+	}
+	if cfg.MaxWorkers < 1 {
+		return importer.Options{}, global, fmt.Errorf("--workers must be >= 1")
+	}
+	if cfg.MaxDepth < 0 {
+		return importer.Options{}, global, fmt.Errorf("--max-depth must be >= 0")
+	}
+	if err := importer.ValidateLayout(cfg.Layout); err != nil {
+		return importer.Options{}, global, fmt.Errorf("invalid --layout: %w", err)
+	}
+	if err := importer.ValidateRename(cfg.Rename); err != nil {
+		return importer.Options{}, global, fmt.Errorf("invalid --rename: %w", err)
+	}
+	if err := importer.ValidateHashAlgorithm(cfg.VerifyHash); err != nil {
+		return importer.Options{}, global, fmt.Errorf("invalid --verify-hash: %w", err)
+	}
+	cfg.VerifyHash = strings.ToLower(cfg.VerifyHash)
+	policy, err := importer.ParseConflictPolicy(cfg.OnConflict)
+	if err != nil {
+		return importer.Options{}, global, fmt.Errorf("invalid --on-conflict: %w", err)
+	}
+	cfg.OnConflict = policy
+	if cfg.Mtime, err = importer.ParseMtime(cfg.Mtime); err != nil {
+		return importer.Options{}, global, fmt.Errorf("invalid --mtime: %w", err)
+	}
+	cfg.Filter = strings.ToLower(cfg.Filter)
+	return cfg, global, nil
+}
+
+func parseUndoFlags(args []string) (importer.UndoOptions, error) {
+	var cfg importer.UndoOptions
+	var global globalOptions
+	fs := newCommandFlagSet("undo", &global)
+	fs.StringVar(&cfg.To, "to", "", "Destination path the run imported into")
+	if err := fs.Parse(args); err != nil {
+		return importer.UndoOptions{}, err
+	}
+	// Accept the run id before or after the flags
+	if rest := fs.Args(); len(rest) > 0 {
+		cfg.RunID = rest[0]
+		if err := fs.Parse(rest[1:]); err != nil {
+			return importer.UndoOptions{}, err
+		}
+		if len(fs.Args()) > 0 {
+			return importer.UndoOptions{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+		}
+	}
+	if cfg.RunID == "" {
+		return importer.UndoOptions{}, fmt.Errorf("need the id of the run to undo")
+	}
+	if cfg.To == "" {
+		return importer.UndoOptions{}, fmt.Errorf("need the destination directory of the run (use '--to')")
+	}
+	return cfg, nil
+}
+
+func parseVerifyFlags(args []string) (importer.VerifyOptions, error) {
+	var cfg importer.VerifyOptions
+	var global globalOptions
+	fs := newCommandFlagSet("verify", &global)
+	fs.StringVar(&cfg.To, "to", "", "Library path to check")
+	// Accept run ids before or after the flags
+	for {
+		if err := fs.Parse(args); err != nil {
+			return importer.VerifyOptions{}, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		cfg.RunIDs = append(cfg.RunIDs, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if cfg.To == "" {
+		return importer.VerifyOptions{}, fmt.Errorf("need the library directory (use '--to')")
+	}
+	if global.Workers < 1 {
+		return importer.VerifyOptions{}, fmt.Errorf("--workers must be >= 1")
+	}
+	cfg.MaxWorkers = global.Workers
+	return cfg, nil
+}
+
+func parseReorganizeFlags(args []string) (importer.ReorganizeOptions, error) {
+	var cfg importer.ReorganizeOptions
+	var global globalOptions
+	fs := newCommandFlagSet("reorganize", &global)
+	fs.StringVar(&cfg.To, "to", "", "Library path to re-sort")
+	fs.StringVar(&cfg.Layout, "layout", importer.DefaultLayout, "New folder template, e.g. {yyyy}/{mm}/{date}")
+	fs.StringVar(&cfg.Rename, "rename", "", "Optional new file name template")
+	fs.BoolVar(&cfg.UseModTime, "fast", false, "Use filesystem modtime instead of parsing EXIF/CR3")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "Print where files would be moved without moving them")
+	loadResolverOptions := addResolverFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return importer.ReorganizeOptions{}, err
+	}
+	if len(fs.Args()) > 0 {
+		return importer.ReorganizeOptions{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if cfg.To == "" {
+		return importer.ReorganizeOptions{}, fmt.Errorf("need the library directory (use '--to')")
+	}
+	if err := importer.ValidateLayout(cfg.Layout); err != nil {
+		return importer.ReorganizeOptions{}, fmt.Errorf("invalid --layout: %w", err)
+	}
+	if err := importer.ValidateRename(cfg.Rename); err != nil {
+		return importer.ReorganizeOptions{}, fmt.Errorf("invalid --rename: %w", err)
+	}
+	timeOpts, err := loadResolverOptions()
+	if err != nil {
+		return importer.ReorganizeOptions{}, err
+	}
+	cfg.TimeOptions = timeOpts
+	return cfg, nil
+}
+
+func parseStatsFlags(args []string) (importer.StatsOptions, error) {
+	var cfg importer.StatsOptions
+	var global globalOptions
+	fs := newCommandFlagSet("stats", &global)
+	fs.StringVar(&cfg.To, "to", "", "Library path to summarize")
+	if err := fs.Parse(args); err != nil {
+		return importer.StatsOptions{}, err
+	}
+	if len(fs.Args()) > 0 {
+		return importer.StatsOptions{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if cfg.To == "" {
+		return importer.StatsOptions{}, fmt.Errorf("need the library directory (use '--to')")
+	}
+	return cfg, nil
+}
+
+func parseInspectFlags(args []string) (importer.InspectOptions, error) {
+	var cfg importer.InspectOptions
+	var global globalOptions
+	fs := newCommandFlagSet("inspect", &global)
+	fs.StringVar(&cfg.To, "to", "", "Destination path used to show where files would be imported")
+	fs.StringVar(&cfg.Layout, "layout", importer.DefaultLayout, "Destination folder template")
+	fs.StringVar(&cfg.Rename, "rename", "", "Optional file name template")
+	loadResolverOptions := addResolverFlags(fs)
+	// Accept paths before or after the flags
+	for {
+		if err := fs.Parse(args); err != nil {
+			return importer.InspectOptions{}, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		cfg.Paths = append(cfg.Paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(cfg.Paths) == 0 {
+		return importer.InspectOptions{}, fmt.Errorf("need at least one file or folder to inspect")
+	}
+	if err := importer.ValidateLayout(cfg.Layout); err != nil {
+		return importer.InspectOptions{}, fmt.Errorf("invalid --layout: %w", err)
+	}
+	if err := importer.ValidateRename(cfg.Rename); err != nil {
+		return importer.InspectOptions{}, fmt.Errorf("invalid --rename: %w", err)
+	}
+	timeOpts, err := loadResolverOptions()
+	if err != nil {
+		return importer.InspectOptions{}, err
+	}
+	cfg.TimeOptions = timeOpts
+	return cfg, nil
+}
+
+// Define the --time-source, --filename-pattern, --clock-rules, --tz and --folder-tz flags on
+// fs, shared by import, plan, reorganize and inspect. The returned function loads and
+// validates them once fs is parsed.
+func addResolverFlags(fs *flag.FlagSet) func() (importer.TimeOptions, error) {
+	var timeSources, clockRulesPath, tz, folderTZ string
+	var filenamePatterns []string
+	fs.StringVar(&timeSources, "time-source", importer.DefaultTimeSources, "Comma-separated places to read the capture time from, tried in order: "+strings.Join(importer.TimeSourceNames(), ", "))
+	fs.Func("filename-pattern", "Regular expression with the named groups year, month and day (and optionally hour, minute, second) that dates files by name, tried before the built-in patterns; may be repeated", func(expr string) error {
+		filenamePatterns = append(filenamePatterns, expr)
+		return nil
+	})
+	fs.StringVar(&clockRulesPath, "clock-rules", "", "JSON file of clock shifts for cameras set to the wrong time, by make, model and serial")
+	fs.StringVar(&tz, "tz", "", "IANA time zone of timestamps recorded without an offset, e.g. Asia/Tokyo (default local time)")
+	fs.StringVar(&folderTZ, "folder-tz", "", "IANA time zone of the folder and file name dates, and of --start/--end when importing (default the zone of each timestamp)")
+
+	return func() (importer.TimeOptions, error) {
+		var opts importer.TimeOptions
+		var err error
+		if opts.Resolvers, err = importer.ParseTimeSources(timeSources); err != nil {
+			return importer.TimeOptions{}, fmt.Errorf("invalid --time-source: %w", err)
+		}
+		if opts.Resolvers, err = withFilenamePatterns(opts.Resolvers, filenamePatterns); err != nil {
+			return importer.TimeOptions{}, fmt.Errorf("invalid --filename-pattern: %w", err)
+		}
+		if clockRulesPath != "" {
+			if opts.ClockRules, err = importer.LoadClockRules(clockRulesPath); err != nil {
+				return importer.TimeOptions{}, fmt.Errorf("invalid --clock-rules: %w", err)
+			}
+		}
+		if opts.TimeZone, err = loadZone(tz); err != nil {
+			return importer.TimeOptions{}, fmt.Errorf("invalid --tz: %w", err)
+		}
+		if opts.FolderTimeZone, err = loadZone(folderTZ); err != nil {
+			return importer.TimeOptions{}, fmt.Errorf("invalid --folder-tz: %w", err)
+		}
+		return opts, nil
+	}
+}
+
+// Replace the filename time source of chain with one that tries the user's patterns first
+func withFilenamePatterns(chain []importer.TimestampResolver, patterns []string) ([]importer.TimestampResolver, error) {
+	if len(patterns) == 0 {
+		return chain, nil
+	}
+	filename, err := importer.NewFilenameResolver(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for i, r := range chain {
+		if r.Name() == importer.TimeSourceFilename {
+			chain[i], found = filename, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("add %s to --time-source to use it", importer.TimeSourceFilename)
+	}
+	return chain, nil
+}
+
+// Load the time zone with an IANA name such as Asia/Tokyo, nil when name is empty
+func loadZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(name)
+}
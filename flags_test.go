@@ -0,0 +1,220 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/renner/file-importer/importer"
+)
+
+func TestParseFlagsNormalizesFilterAndWorkers(t *testing.T) {
+	cfg, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--filter", "JPG", "--workers", "3"})
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if cfg.Filter != "jpg" {
+		t.Fatalf("expected lower-cased filter, got: %q", cfg.Filter)
+	}
+	if cfg.MaxWorkers != 3 {
+		t.Fatalf("expected workers=3, got: %d", cfg.MaxWorkers)
+	}
+}
+
+func TestParseFlagsRejectsInvalidWorkers(t *testing.T) {
+	_, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--workers", "0"})
+	if err == nil {
+		t.Fatal("expected error for workers=0")
+	}
+	if !strings.Contains(err.Error(), "--workers") {
+		t.Fatalf("expected workers validation error, got: %v", err)
+	}
+}
+
+func TestParseFlagsParsesValidDates(t *testing.T) {
+	cfg, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--start", "2024-03-03", "--end", "2024-03-31"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if cfg.Start.Year() != 2024 || cfg.Start.Month() != 3 || cfg.Start.Day() != 3 {
+		t.Fatalf("expected start 2024-03-03, got: %v", cfg.Start)
+	}
+	if cfg.End.Year() != 2024 || cfg.End.Month() != 3 || cfg.End.Day() != 31 {
+		t.Fatalf("expected end 2024-03-31, got: %v", cfg.End)
+	}
+	if cfg.End.Hour() != 23 || cfg.End.Minute() != 59 || cfg.End.Second() != 59 {
+		t.Fatalf("expected end time 23:59:59, got: %v", cfg.End)
+	}
+}
+
+func TestParseFlagsRejectsInvalidStartOrEnd(t *testing.T) {
+	_, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--start", "invalid"})
+	if err == nil || !strings.Contains(err.Error(), "invalid start date format") {
+		t.Fatalf("expected start date validation error, got: %v", err)
+	}
+
+	_, err = parseFlags([]string{"--from", "/src", "--to", "/dst", "--end", "2024-99-99"})
+	if err == nil || !strings.Contains(err.Error(), "invalid end date format") {
+		t.Fatalf("expected end date validation error, got: %v", err)
+	}
+}
+
+func TestParseFlagsSetsUseModTime(t *testing.T) {
+	cfg, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--fast"})
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if !cfg.UseModTime {
+		t.Fatalf("expected UseModTime to be true when --fast is provided")
+	}
+}
+
+func TestParseFlagsRejectsNegativeMaxDepth(t *testing.T) {
+	_, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--recursive", "--max-depth", "-1"})
+	if err == nil || !strings.Contains(err.Error(), "--max-depth") {
+		t.Fatalf("expected max-depth validation error, got: %v", err)
+	}
+}
+
+func TestParseFlagsRejectsUnknownLayoutToken(t *testing.T) {
+	_, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--layout", "{yyyy}/{foo}"})
+	if err == nil || !strings.Contains(err.Error(), "invalid --layout") {
+		t.Fatalf("expected layout validation error, got: %v", err)
+	}
+}
+
+func TestParseFlagsRejectsUnknownConflictPolicy(t *testing.T) {
+	_, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--on-conflict", "merge"})
+	if err == nil || !strings.Contains(err.Error(), "invalid --on-conflict") {
+		t.Fatalf("expected conflict policy validation error, got: %v", err)
+	}
+}
+
+func TestParseFlagsValidatesVerifyHash(t *testing.T) {
+	cfg, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--verify", "--verify-hash", "MD5"})
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if !cfg.Verify || cfg.VerifyHash != "md5" {
+		t.Fatalf("expected verify with md5, got verify=%v hash=%q", cfg.Verify, cfg.VerifyHash)
+	}
+
+	_, err = parseFlags([]string{"--from", "/src", "--to", "/dst", "--verify-hash", "rot13"})
+	if err == nil || !strings.Contains(err.Error(), "invalid --verify-hash") {
+		t.Fatalf("expected verify-hash validation error, got: %v", err)
+	}
+}
+
+func TestParseFlagsBuildsTimeSourceChain(t *testing.T) {
+	cfg, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--time-source", "xmp,filename,mtime"})
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if len(cfg.Resolvers) != 3 || cfg.Resolvers[0].Name() != "xmp" || cfg.Resolvers[2].Name() != "modtime" {
+		t.Fatalf("unexpected resolver chain: %v", cfg.Resolvers)
+	}
+
+	_, err = parseFlags([]string{"--from", "/src", "--to", "/dst", "--time-source", "exif,sundial"})
+	if err == nil || !strings.Contains(err.Error(), "invalid --time-source") {
+		t.Fatalf("expected time-source validation error, got: %v", err)
+	}
+}
+
+func TestParseUndoFlagsAcceptsRunIDBeforeOrAfterFlags(t *testing.T) {
+	for _, args := range [][]string{{"20240603-142233-ab12", "--to", "/dst"}, {"--to", "/dst", "20240603-142233-ab12"}} {
+		cfg, err := parseUndoFlags(args)
+		if err != nil {
+			t.Fatalf("parseUndoFlags(%v) returned error: %v", args, err)
+		}
+		if cfg.RunID != "20240603-142233-ab12" || cfg.To != "/dst" {
+			t.Fatalf("unexpected config for %v: %+v", args, cfg)
+		}
+	}
+	if _, err := parseUndoFlags([]string{"--to", "/dst"}); err == nil {
+		t.Fatal("expected error without run id")
+	}
+}
+
+func TestParseFlagsValidatesMtime(t *testing.T) {
+	cfg, err := parseFlags([]string{"--from", "/src", "--to", "/dst"})
+	if err != nil || cfg.Mtime != "source" {
+		t.Fatalf("expected the source mtime by default, got %q (%v)", cfg.Mtime, err)
+	}
+	cfg, err = parseFlags([]string{"--from", "/src", "--to", "/dst", "--mtime", "capture"})
+	if err != nil || cfg.Mtime != "capture" {
+		t.Fatalf("expected the capture mtime, got %q (%v)", cfg.Mtime, err)
+	}
+	_, err = parseFlags([]string{"--from", "/src", "--to", "/dst", "--mtime", "now"})
+	if err == nil || !strings.Contains(err.Error(), "invalid --mtime") {
+		t.Fatalf("expected mtime validation error, got: %v", err)
+	}
+}
+
+func TestParseFlagsLoadsTimeZones(t *testing.T) {
+	cfg, err := parseFlags([]string{"--from", "/src", "--to", "/dst", "--tz", "Asia/Tokyo", "--folder-tz", "Asia/Tokyo", "--start", "2024-06-02"})
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if cfg.TimeZone == nil || cfg.TimeZone.String() != "Asia/Tokyo" || cfg.FolderTimeZone == nil {
+		t.Fatalf("expected the Tokyo zones, got %v and %v", cfg.TimeZone, cfg.FolderTimeZone)
+	}
+	if want := time.Date(2024, 6, 2, 0, 0, 0, 0, cfg.FolderTimeZone); !cfg.Start.Equal(want) {
+		t.Fatalf("expected the window to start at %v, got %v", want, cfg.Start)
+	}
+	for _, flag := range []string{"--tz", "--folder-tz"} {
+		_, err = parseFlags([]string{"--from", "/src", "--to", "/dst", flag, "Mars/Olympus"})
+		if err == nil || !strings.Contains(err.Error(), "invalid "+flag) {
+			t.Fatalf("expected %s validation error, got: %v", flag, err)
+		}
+	}
+}
+
+func TestParseFlagsAddsFilenamePatterns(t *testing.T) {
+	cfg, err := parseFlags([]string{"--from", "/src", "--to", "/dst",
+		"--filename-pattern", `^DJI_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`, "--filename-pattern", `^GX(?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2})`})
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	var names []string
+	for _, r := range cfg.Resolvers {
+		names = append(names, r.Name())
+	}
+	if got := strings.Join(names, ","); got != "exif,cr3,video,filename,modtime" {
+		t.Fatalf("unexpected chain %s", got)
+	}
+	_, err = parseFlags([]string{"--from", "/src", "--to", "/dst", "--filename-pattern", `^DJI_(?P<year>\d{4})`})
+	if err == nil || !strings.Contains(err.Error(), "invalid --filename-pattern") {
+		t.Fatalf("expected filename pattern validation error, got: %v", err)
+	}
+	_, err = parseFlags([]string{"--from", "/src", "--to", "/dst", "--time-source", "exif", "--filename-pattern", `(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`})
+	if err == nil || !strings.Contains(err.Error(), "add filename to --time-source") {
+		t.Fatalf("expected an error for a pattern without the filename time source, got: %v", err)
+	}
+}
+
+func TestReorganizeAndInspectShareResolverFlags(t *testing.T) {
+	shared := []string{"--time-source", "exif,filename", "--tz", "Asia/Tokyo", "--folder-tz", "UTC",
+		"--filename-pattern", `^DJI_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`}
+	reorganize, err := parseReorganizeFlags(append([]string{"--to", "/dst"}, shared...))
+	if err != nil {
+		t.Fatalf("parseReorganizeFlags returned error: %v", err)
+	}
+	inspect, err := parseInspectFlags(append([]string{"photo.jpg"}, shared...))
+	if err != nil {
+		t.Fatalf("parseInspectFlags returned error: %v", err)
+	}
+	for name, got := range map[string]struct {
+		resolvers    []importer.TimestampResolver
+		tz, folderTZ *time.Location
+	}{
+		"reorganize": {reorganize.Resolvers, reorganize.TimeZone, reorganize.FolderTimeZone},
+		"inspect":    {inspect.Resolvers, inspect.TimeZone, inspect.FolderTimeZone},
+	} {
+		if len(got.resolvers) != 2 || got.tz == nil || got.tz.String() != "Asia/Tokyo" || got.folderTZ == nil || got.folderTZ.String() != "UTC" {
+			t.Fatalf("%s: unexpected resolver options %v %v %v", name, got.resolvers, got.tz, got.folderTZ)
+		}
+	}
+	if _, err := parseInspectFlags([]string{"photo.jpg", "--tz", "Mars/Olympus"}); err == nil || !strings.Contains(err.Error(), "invalid --tz") {
+		t.Fatalf("expected --tz validation error, got: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cancelWriter cancels a context as soon as a line containing trigger is written
+type cancelWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	trigger string
+	cancel  context.CancelFunc
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if strings.Contains(string(p), w.trigger) {
+		w.cancel()
+	}
+	return w.buf.Write(p)
+}
+
+func TestCopyFileContentsAbortsWhenCancelled(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src.txt")
+	dstDir := filepath.Join(tmp, "out")
+	if err := os.Mkdir(dstDir, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	mustWriteFile(t, src, "partial")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := copyFileContentsWith(ctx, src, filepath.Join(dstDir, "dst.txt"), time.Now(), copyOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	entries, err := os.ReadDir(dstDir)
+	if err != nil {
+		t.Fatalf("read dir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no partial output, got %d entries", len(entries))
+	}
+}
+
+func TestRunImportStopsWhenCancelled(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		path := filepath.Join(from, name)
+		mustWriteFile(t, path, name)
+		mustSetMtime(t, path, time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Cancel while the first file is being copied
+	out := &cancelWriter{trigger: "Copying", cancel: cancel}
+	reportPath := filepath.Join(root, "report.json")
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+		UseModTime: true,
+		Report:     reportPath,
+	}
+	summary, err := runImport(ctx, cfg, out, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v\noutput:\n%s", err, out.buf.String())
+	}
+	if !summary.Interrupted || summary.Copied != 0 || summary.Failed != 0 {
+		t.Fatalf("expected an interrupted run without copies or failures, got: %+v", summary)
+	}
+	if !strings.Contains(out.buf.String(), "Interrupted. processed=") {
+		t.Fatalf("expected interrupted summary line, got:\n%s", out.buf.String())
+	}
+
+	var leftovers []string
+	filepath.WalkDir(to, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && !strings.Contains(path, metaDirName) {
+			leftovers = append(leftovers, path)
+		}
+		return nil
+	})
+	if len(leftovers) != 0 {
+		t.Fatalf("expected no files in the destination, got: %v", leftovers)
+	}
+	if report := readFileString(t, reportPath); !strings.Contains(report, `"interrupted": true`) {
+		t.Fatalf("expected report to mark the run as interrupted:\n%s", report)
+	}
+}
+
+func TestLibraryIndexAndHashingStopWhenCancelled(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.jpg")
+	mustWriteFile(t, path, "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := loadLibraryIndex(ctx, root, t.Logf); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected loadLibraryIndex to return context.Canceled, got: %v", err)
+	}
+	if _, err := hashFile(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected hashFile to return context.Canceled, got: %v", err)
+	}
+	if _, err := sameContent(ctx, path, path); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected sameContent to return context.Canceled, got: %v", err)
+	}
+}
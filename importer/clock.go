@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ClockRule corrects the timestamps of a camera whose clock is set wrong by adding Shift.
+// Empty Make, Model and Serial fields match any camera, Make and Model compare
+// case-insensitively. Serial is EXIF BodySerialNumber.
+type ClockRule struct {
+	Make   string
+	Model  string
+	Serial string
+	Shift  time.Duration
+}
+
+// clockRuleJSON is a rule as written in a rules file, with the shift as a Go duration such
+// as "-1h" or "+3m20s"
+type clockRuleJSON struct {
+	Make   string `json:"make,omitempty"`
+	Model  string `json:"model,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	Shift  string `json:"shift"`
+}
+
+// LoadClockRules reads a JSON array of rules such as
+// [{"make": "Canon", "model": "Canon EOS R6", "serial": "012345", "shift": "-1h"}]
+func LoadClockRules(path string) ([]ClockRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw []clockRuleJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	rules := make([]ClockRule, 0, len(raw))
+	for i, r := range raw {
+		shift, err := time.ParseDuration(strings.TrimSpace(r.Shift))
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %d: invalid shift: %w", path, i+1, err)
+		}
+		rule := ClockRule{Make: r.Make, Model: r.Model, Serial: r.Serial, Shift: shift}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r ClockRule) validate() error {
+	if r.Make == "" && r.Model == "" && r.Serial == "" {
+		return fmt.Errorf("rule matches every camera, set make, model or serial")
+	}
+	if r.Shift == 0 {
+		return fmt.Errorf("rule has no shift")
+	}
+	return nil
+}
+
+// Report whether the rule applies to the camera in meta
+func (r ClockRule) matches(meta FileMeta) bool {
+	if r.Make != "" && !strings.EqualFold(strings.TrimSpace(r.Make), strings.TrimSpace(meta.Make)) {
+		return false
+	}
+	if r.Model != "" && !strings.EqualFold(strings.TrimSpace(r.Model), strings.TrimSpace(meta.Model)) {
+		return false
+	}
+	if r.Serial != "" && strings.TrimSpace(r.Serial) != strings.TrimSpace(meta.Serial) {
+		return false
+	}
+	return true
+}
+
+// Describe the camera the rule applies to
+func (r ClockRule) String() string {
+	var parts []string
+	if name := cameraName(r.Make, r.Model); name != "" {
+		parts = append(parts, name)
+	}
+	if r.Serial != "" {
+		parts = append(parts, "serial "+r.Serial)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Find the first rule for the camera in meta
+func findClockRule(rules []ClockRule, meta FileMeta) (ClockRule, bool) {
+	for _, r := range rules {
+		if r.matches(meta) {
+			return r, true
+		}
+	}
+	return ClockRule{}, false
+}
+
+// Format a shift with an explicit sign, e.g. "+1h0m0s"
+func formatShift(d time.Duration) string {
+	if d > 0 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
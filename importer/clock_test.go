@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadClockRules(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.json")
+	mustWriteFile(t, path, `[
+		{"make": "Canon", "model": "Canon EOS R6", "serial": "012345", "shift": "-1h"},
+		{"make": "SONY", "shift": "+3m20s"}
+	]`)
+	rules, err := LoadClockRules(path)
+	if err != nil {
+		t.Fatalf("LoadClockRules returned error: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Shift != -time.Hour || rules[1].Shift != 200*time.Second || rules[0].Serial != "012345" {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+
+	for content, want := range map[string]string{
+		`[{"make": "Canon", "shift": "soon"}]`: "rule 1: invalid shift",
+		`[{"shift": "1h"}]`:                    "rule 1: rule matches every camera",
+		`[{"model": "X100V", "shift": "0s"}]`:  "rule 1: rule has no shift",
+		`{"make": "Canon"}`:                    "parse",
+	} {
+		mustWriteFile(t, path, content)
+		if _, err := LoadClockRules(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("LoadClockRules(%s): expected error containing %q, got: %v", content, want, err)
+		}
+	}
+}
+
+func TestClockRuleMatchesMostSpecificFields(t *testing.T) {
+	meta := FileMeta{Make: "Canon", Model: "Canon EOS R6", Serial: "012345"}
+	rules := []ClockRule{
+		{Make: "Canon", Serial: "999999", Shift: time.Hour},
+		{Make: "canon", Model: "CANON EOS R6", Shift: 2 * time.Hour},
+		{Make: "Canon", Shift: 3 * time.Hour},
+	}
+	rule, ok := findClockRule(rules, meta)
+	if !ok || rule.Shift != 2*time.Hour {
+		t.Fatalf("expected the first matching rule, got: %+v", rule)
+	}
+	if _, ok := findClockRule(rules, FileMeta{Make: "Nikon"}); ok {
+		t.Fatal("expected no rule for another camera")
+	}
+}
+
+func TestImportShiftsCameraClockBeforeWindowAndLayout(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	tiff := buildTIFF(
+		[]tiffEntry{asciiEntry(0x010f, "Canon"), asciiEntry(0x0110, "Canon EOS R6")},
+		[]tiffEntry{asciiEntry(0x9003, "2024:06:01 00:30:00"), asciiEntry(0x9011, "+00:00"), asciiEntry(0xa431, "012345")},
+		nil,
+	)
+	mustWriteBytes(t, filepath.Join(from, "IMG_0001.JPG"), buildJPEG(tiff))
+
+	reportPath := filepath.Join(root, "report.json")
+	var out bytes.Buffer
+	im, err := New(Options{
+		From:        from,
+		To:          to,
+		Layout:      "{date}",
+		End:         time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC),
+		Report:      reportPath,
+		TimeOptions: TimeOptions{ClockRules: []ClockRule{{Serial: "012345", Shift: -time.Hour}}},
+		Output:      &out,
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	summary, err := im.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 1 {
+		t.Fatalf("expected the shifted file inside the window, got: %+v\noutput:\n%s", summary, out.String())
+	}
+	utcDate := summary.Files[0].Meta.Timestamp.Format("2006-01-02")
+	if _, err := os.Stat(filepath.Join(to, utcDate, "IMG_0001.JPG")); err != nil || summary.Files[0].Meta.ClockShift != -time.Hour {
+		t.Fatalf("expected the file in the folder of the shifted time: %v (%+v)", err, summary.Files[0].Meta)
+	}
+	if !strings.Contains(out.String(), "IMG_0001.JPG: clock shifted by -1h0m0s for serial 012345") {
+		t.Fatalf("expected the shift in the output:\n%s", out.String())
+	}
+
+	var report runReport
+	if err := json.Unmarshal([]byte(readFileString(t, reportPath)), &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if len(report.Files) != 1 || report.Files[0].ClockShift != "-1h0m0s" {
+		t.Fatalf("expected the shift in the report: %+v", report.Files)
+	}
+}
@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policies for --on-conflict, applied when the destination file already exists
+const (
+	conflictSkip            = "skip"
+	conflictOverwrite       = "overwrite"
+	conflictRename          = "rename"
+	conflictSkipIfIdentical = "skip-if-identical"
+	conflictFail            = "fail"
+)
+
+const DefaultConflictPolicy = conflictSkipIfIdentical
+
+var conflictPolicies = []string{conflictSkip, conflictOverwrite, conflictRename, conflictSkipIfIdentical, conflictFail}
+
+// Validate an --on-conflict value, mapping the empty string to the default policy
+func ParseConflictPolicy(policy string) (string, error) {
+	if policy == "" {
+		return DefaultConflictPolicy, nil
+	}
+	for _, p := range conflictPolicies {
+		if policy == p {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (use one of %s)", policy, strings.Join(conflictPolicies, ", "))
+}
+
+// Report whether two files have the same size and SHA-256 digest
+func sameContent(ctx context.Context, a, b string) (bool, error) {
+	afi, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bfi, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if afi.Size() != bfi.Size() {
+		return false, nil
+	}
+	ah, err := hashFile(ctx, a)
+	if err != nil {
+		return false, err
+	}
+	bh, err := hashFile(ctx, b)
+	if err != nil {
+		return false, err
+	}
+	return ah == bh, nil
+}
+
+// Build the n-th alternative for a conflicting file name: IMG_0001.JPG -> IMG_0001-2.JPG
+func alternativeName(name string, n int) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+}
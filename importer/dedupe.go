@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metaDirName is the folder below the destination root where the importer keeps its own state
+const metaDirName = ".file-importer"
+
+const indexFileName = "index.json"
+
+// indexEntry records the content hash of a library file together with the size and mtime it
+// had when it was hashed, so unchanged files are not hashed again on the next run
+type indexEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// libraryIndex maps every file below the destination root to its content hash. It is shared
+// by all workers of a run.
+type libraryIndex struct {
+	root string
+
+	mu     sync.Mutex
+	files  map[string]indexEntry // keyed by slash-separated path relative to root
+	byHash map[string]string     // hash -> relative path, including files still being copied
+}
+
+// Load the persisted index for root and bring it up to date with what is on disk. Files whose
+// size and mtime are unchanged keep their stored hash, everything else is hashed again.
+func loadLibraryIndex(ctx context.Context, root string, logf func(string, ...any)) (*libraryIndex, error) {
+	idx := &libraryIndex{root: root, files: make(map[string]indexEntry), byHash: make(map[string]string)}
+
+	stored := map[string]indexEntry{}
+	b, err := os.ReadFile(filepath.Join(root, metaDirName, indexFileName))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, &stored); err != nil {
+			logf("Ignoring unreadable library index: %v", err)
+			stored = map[string]indexEntry{}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		entry, ok := stored[rel]
+		if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+			sum, err := hashFile(ctx, path)
+			if err != nil {
+				return err
+			}
+			entry = indexEntry{Size: info.Size(), ModTime: info.ModTime(), SHA256: sum}
+		}
+		idx.files[rel] = entry
+		idx.byHash[entry.SHA256] = rel
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("index %s failed: %w", root, err)
+	}
+	return idx, nil
+}
+
+// Reserve a hash for the source file rel. If the hash is already known (from the library or
+// an earlier file of this run) the existing path is returned with false.
+func (idx *libraryIndex) reserve(sum, rel string) (string, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if existing, ok := idx.byHash[sum]; ok {
+		return existing, false
+	}
+	idx.byHash[sum] = rel
+	return "", true
+}
+
+// Drop a reservation for a file that ended up not being imported
+func (idx *libraryIndex) release(sum string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.byHash, sum)
+}
+
+// Record a newly imported file at its absolute destination path
+func (idx *libraryIndex) add(path, sum string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(idx.root, path)
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.files[rel] = indexEntry{Size: info.Size(), ModTime: info.ModTime(), SHA256: sum}
+	idx.byHash[sum] = rel
+	return nil
+}
+
+// Persist the index below the destination root so the next run only hashes new files
+func (idx *libraryIndex) save() error {
+	idx.mu.Lock()
+	b, err := json.MarshalIndent(idx.files, "", "  ")
+	idx.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(idx.root, metaDirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, indexFileName+".tmp")
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, indexFileName))
+}
@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadLibraryIndexReusesStoredHashes(t *testing.T) {
+	root := t.TempDir()
+	folder := filepath.Join(root, "2024-07-08-jpg")
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	path := filepath.Join(folder, "a.jpg")
+	mustWriteFile(t, path, "library content")
+
+	idx, err := loadLibraryIndex(context.Background(), root, t.Logf)
+	if err != nil {
+		t.Fatalf("loadLibraryIndex returned error: %v", err)
+	}
+	if err := idx.save(); err != nil {
+		t.Fatalf("save returned error: %v", err)
+	}
+
+	// Tamper with the stored hash; an unchanged file must keep it instead of being re-hashed
+	indexPath := filepath.Join(root, metaDirName, indexFileName)
+	stored := readFileString(t, indexPath)
+	sum := idx.files["2024-07-08-jpg/a.jpg"].SHA256
+	mustWriteFile(t, indexPath, strings.Replace(stored, sum, "cached", 1))
+
+	idx, err = loadLibraryIndex(context.Background(), root, t.Logf)
+	if err != nil {
+		t.Fatalf("loadLibraryIndex returned error: %v", err)
+	}
+	if got := idx.files["2024-07-08-jpg/a.jpg"].SHA256; got != "cached" {
+		t.Fatalf("expected stored hash to be reused, got %q", got)
+	}
+
+	mustSetMtime(t, path, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	idx, err = loadLibraryIndex(context.Background(), root, t.Logf)
+	if err != nil {
+		t.Fatalf("loadLibraryIndex returned error: %v", err)
+	}
+	if got := idx.files["2024-07-08-jpg/a.jpg"].SHA256; got != sum {
+		t.Fatalf("expected changed file to be re-hashed, got %q", got)
+	}
+}
+
+func TestRunImportDedupeSkipsFilesAlreadyInLibrary(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	renamed := filepath.Join(to, "sorted", "holiday.jpg")
+	if err := os.MkdirAll(filepath.Dir(renamed), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	mustWriteFile(t, renamed, "already imported")
+
+	mtime := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+	for name, content := range map[string]string{"a.jpg": "already imported", "b.jpg": "new", "c.jpg": "new"} {
+		path := filepath.Join(from, name)
+		mustWriteFile(t, path, content)
+		mustSetMtime(t, path, mtime)
+	}
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+		UseModTime: true,
+		Dedupe:     true,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 1 || summary.Duplicates != 2 {
+		t.Fatalf("expected one copy and two duplicates, got: %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(to, "2024-07-08-jpg", "a.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("expected duplicate of renamed library file to be skipped, stat err=%v", err)
+	}
+
+	// The second run is incremental and finds everything in the persisted index
+	out.Reset()
+	summary, err = runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 0 || summary.Duplicates != 3 {
+		t.Fatalf("expected all files to be duplicates on rerun, got: %+v", summary)
+	}
+}
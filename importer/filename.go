@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filenamePattern finds a date, and usually a time, in a file name with the named groups
+// year, month and day, optionally hour, minute, second, subsec (digits of a fraction of a
+// second) and ampm (AM or PM for a 12-hour clock)
+type filenamePattern struct {
+	re *regexp.Regexp
+	// user marks patterns given by the user, whose reason names them
+	user bool
+}
+
+// Built-in patterns, tried in order
+var builtinFilenamePatterns = []filenamePattern{
+	// Cameras and phones: IMG_20240601_142233.jpg, 20240601-142233.mp4,
+	// 2024-06-01 14.22.33.png and PXL_20240601_142233123.jpg with milliseconds
+	{re: regexp.MustCompile(`(?:^|\D)(?P<year>\d{4})-?(?P<month>\d{2})-?(?P<day>\d{2})[_\- T]?(?P<hour>\d{2})[\-.:]?(?P<minute>\d{2})[\-.:]?(?P<second>\d{2})(?P<subsec>\d{3})?(?:\D|$)`)},
+	// macOS screenshots and recordings: "Screenshot 2024-06-01 at 14.22.33.png" or, with a
+	// 12-hour clock, "Screen Shot 2020-06-01 at 2.22.33 PM.png"
+	{re: regexp.MustCompile(`(?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2}) at (?P<hour>\d{1,2})\.(?P<minute>\d{2})\.(?P<second>\d{2})(?:[\s\x{202f}]?(?P<ampm>[AaPp][Mm]))?`)},
+	// WhatsApp media, which only carry the date: IMG-20240601-WA0003.jpg, VID-20240601-WA0003.mp4
+	{re: regexp.MustCompile(`(?:IMG|VID|AUD|PTT|STK|DOC)-(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})-WA\d+`)},
+	// A date on its own, such as scan-2024-06-01.jpg
+	{re: regexp.MustCompile(`(?:^|\D)(?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2})(?:\D|$)`)},
+}
+
+// Confidence of a timestamp from a file name, and of a date without a time
+const (
+	filenameConfidence     = 0.6
+	filenameDateConfidence = 0.4
+)
+
+// ParseFilenamePattern compiles a regular expression that finds a capture date in file names.
+// It must have the named groups year, month and day, and may have hour, minute, second,
+// subsec and ampm, e.g. `^DJI_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`.
+func ParseFilenamePattern(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]bool)
+	for _, name := range re.SubexpNames() {
+		groups[name] = true
+	}
+	if !groups["year"] || !groups["month"] || !groups["day"] {
+		return nil, fmt.Errorf("pattern %q needs the named groups year, month and day", expr)
+	}
+	if (groups["minute"] || groups["second"]) && !groups["hour"] {
+		return nil, fmt.Errorf("pattern %q has minutes or seconds but no hour group", expr)
+	}
+	return re, nil
+}
+
+// NewFilenameResolver returns the filename time source with user patterns that are tried
+// before the built-in ones. See ParseFilenamePattern for their groups.
+func NewFilenameResolver(patterns ...string) (TimestampResolver, error) {
+	r := filenameResolver{}
+	for _, expr := range patterns {
+		re, err := ParseFilenamePattern(expr)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, filenamePattern{re: re, user: true})
+	}
+	r.patterns = append(r.patterns, builtinFilenamePatterns...)
+	return r, nil
+}
+
+// Derive a timestamp in loc from a file name with the first pattern that matches a valid
+// date. hasTime is false for names with only a date, which are dated at noon so a shift
+// of the zone keeps the day.
+func timestampFromFilename(name string, loc *time.Location, patterns []filenamePattern) (t time.Time, hasTime bool, p filenamePattern, ok bool) {
+	for _, p := range patterns {
+		for _, m := range p.re.FindAllStringSubmatch(name, -1) {
+			if t, hasTime, ok := filenameMatchTime(p.re, m, loc); ok {
+				return t, hasTime, p, true
+			}
+		}
+	}
+	return time.Time{}, false, filenamePattern{}, false
+}
+
+// Build the time of a pattern match, rejecting impossible dates such as 2024-02-30
+func filenameMatchTime(re *regexp.Regexp, m []string, loc *time.Location) (time.Time, bool, bool) {
+	groups := make(map[string]string)
+	for i, name := range re.SubexpNames() {
+		if name != "" && m[i] != "" {
+			groups[name] = m[i]
+		}
+	}
+	number := func(name string, min, max int) (int, bool) {
+		v, err := strconv.Atoi(groups[name])
+		return v, err == nil && v >= min && v <= max
+	}
+	year, okYear := number("year", 1900, 2099)
+	month, okMonth := number("month", 1, 12)
+	day, okDay := number("day", 1, 31)
+	if !okYear || !okMonth || !okDay {
+		return time.Time{}, false, false
+	}
+	if _, ok := groups["hour"]; !ok {
+		t := time.Date(year, time.Month(month), day, 12, 0, 0, 0, loc)
+		return t, false, t.Day() == day
+	}
+
+	hour, okHour := number("hour", 0, 23)
+	var minute, second int
+	okMinute, okSecond := true, true
+	if _, ok := groups["minute"]; ok {
+		minute, okMinute = number("minute", 0, 59)
+	}
+	if _, ok := groups["second"]; ok {
+		second, okSecond = number("second", 0, 59)
+	}
+	if !okHour || !okMinute || !okSecond {
+		return time.Time{}, false, false
+	}
+	switch strings.ToUpper(groups["ampm"]) {
+	case "AM", "PM":
+		if hour < 1 || hour > 12 {
+			return time.Time{}, false, false
+		}
+		hour %= 12
+		if strings.ToUpper(groups["ampm"]) == "PM" {
+			hour += 12
+		}
+	}
+	subSec, _ := parseSubSec(groups["subsec"])
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, loc).Add(subSec)
+	return t, true, t.Day() == day
+}
@@ -0,0 +1,203 @@
+package importer
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Zones found by GPS position must load on systems without a zone database
+	_ "time/tzdata"
+)
+
+// zonesData holds the simplified time zone boundaries, see the comment at its top
+//
+//go:embed zones.txt
+var zonesData string
+
+// zoneArea is one polygon of a time zone, a ring of vertices in degrees with its bounding
+// box to skip it quickly
+type zoneArea struct {
+	zone                     string
+	lats, lons               []float64
+	south, north, west, east float64
+}
+
+// Report whether the position lies inside the polygon, by counting the edges a ray from it
+// towards the east crosses
+func (a *zoneArea) contains(lat, lon float64) bool {
+	if lat < a.south || lat > a.north || lon < a.west || lon > a.east {
+		return false
+	}
+	inside := false
+	for i, j := 0, len(a.lats)-1; i < len(a.lats); j, i = i, i+1 {
+		if (a.lats[i] > lat) != (a.lats[j] > lat) &&
+			lon < a.lons[j]+(lat-a.lats[j])*(a.lons[i]-a.lons[j])/(a.lats[i]-a.lats[j]) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// area is the size of the polygon in square degrees, which is enough to order nested ones
+func (a *zoneArea) area() float64 {
+	var sum float64
+	for i, j := 0, len(a.lats)-1; i < len(a.lats); j, i = i, i+1 {
+		sum += a.lons[j]*a.lats[i] - a.lons[i]*a.lats[j]
+	}
+	return math.Abs(sum) / 2
+}
+
+var (
+	zoneAreasOnce sync.Once
+	zoneAreas     []*zoneArea
+	zoneAreasErr  error
+
+	// Locations already loaded by zoneAt, by name
+	zoneLocations sync.Map
+)
+
+// Parse the zone polygons, smallest first so a lookup can stop at the first match. A line
+// without indentation names the zone of a new polygon, the indented lines after it list its
+// vertices as lat,lon pairs.
+func parseZoneAreas(data string) ([]*zoneArea, error) {
+	var areas []*zoneArea
+	var current *zoneArea
+	finish := func(line int) error {
+		if current == nil {
+			return nil
+		}
+		if len(current.lats) < 3 {
+			return fmt.Errorf("line %d: polygon of %s has fewer than three vertices", line, current.zone)
+		}
+		areas = append(areas, current)
+		current = nil
+		return nil
+	}
+	lines := strings.Split(data, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if trimmed == line {
+			if err := finish(i); err != nil {
+				return nil, err
+			}
+			if strings.ContainsAny(trimmed, " \t,") {
+				return nil, fmt.Errorf("line %d: expected a zone name", i+1)
+			}
+			current = &zoneArea{zone: trimmed, south: 90, north: -90, west: 180, east: -180}
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: vertices before a zone name", i+1)
+		}
+		for _, pair := range strings.Fields(trimmed) {
+			latStr, lonStr, ok := strings.Cut(pair, ",")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected lat,lon in %q", i+1, pair)
+			}
+			lat, err := strconv.ParseFloat(latStr, 64)
+			if err != nil || lat < -90 || lat > 90 {
+				return nil, fmt.Errorf("line %d: invalid latitude in %q", i+1, pair)
+			}
+			lon, err := strconv.ParseFloat(lonStr, 64)
+			if err != nil || lon < -180 || lon > 180 {
+				return nil, fmt.Errorf("line %d: invalid longitude in %q", i+1, pair)
+			}
+			current.lats = append(current.lats, lat)
+			current.lons = append(current.lons, lon)
+			current.south, current.north = min(current.south, lat), max(current.north, lat)
+			current.west, current.east = min(current.west, lon), max(current.east, lon)
+		}
+	}
+	if err := finish(len(lines)); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(areas, func(i, j int) bool { return areas[i].area() < areas[j].area() })
+	return areas, nil
+}
+
+// Find the time zone at a position, nil when it lies outside every known zone
+func zoneAt(lat, lon float64) *time.Location {
+	zoneAreasOnce.Do(func() {
+		zoneAreas, zoneAreasErr = parseZoneAreas(zonesData)
+	})
+	if zoneAreasErr != nil {
+		return nil
+	}
+	for _, a := range zoneAreas {
+		if !a.contains(lat, lon) {
+			continue
+		}
+		if loc, ok := zoneLocations.Load(a.zone); ok {
+			return loc.(*time.Location)
+		}
+		loc, err := time.LoadLocation(a.zone)
+		if err != nil {
+			return nil
+		}
+		zoneLocations.Store(a.zone, loc)
+		return loc
+	}
+	return nil
+}
+
+// Parse an EXIF GPS coordinate given as degrees, minutes and seconds separated by spaces and
+// its N/S or E/W reference
+func parseGPSCoordinate(value, ref string, limit float64) (float64, bool) {
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return 0, false
+	}
+	var dms [3]float64
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil || v < 0 {
+			return 0, false
+		}
+		dms[i] = v
+	}
+	deg := dms[0] + dms[1]/60 + dms[2]/3600
+	switch strings.ToUpper(strings.TrimSpace(ref)) {
+	case "S", "W":
+		deg = -deg
+	case "N", "E", "":
+	default:
+		return 0, false
+	}
+	if deg < -limit || deg > limit {
+		return 0, false
+	}
+	return deg, true
+}
+
+// Find the time zone at the GPS position recorded in the EXIF tags, nil without one
+func (t *exifTags) gpsZone() *time.Location {
+	if t.gpsLatitude == "" || t.gpsLongitude == "" {
+		return nil
+	}
+	lat, ok := parseGPSCoordinate(t.gpsLatitude, t.gpsLatitudeRef, 90)
+	if !ok {
+		return nil
+	}
+	lon, ok := parseGPSCoordinate(t.gpsLongitude, t.gpsLongitudeRef, 180)
+	if !ok {
+		return nil
+	}
+	return positionZone(lat, lon)
+}
+
+// Find the time zone at a recorded GPS position, nil without a fix
+func positionZone(lat, lon float64) *time.Location {
+	// Cameras without a fix write zeros
+	if lat == 0 && lon == 0 {
+		return nil
+	}
+	return zoneAt(lat, lon)
+}
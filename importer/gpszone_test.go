@@ -0,0 +1,185 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestZonesDataNamesLoadableZones(t *testing.T) {
+	areas, err := parseZoneAreas(zonesData)
+	if err != nil {
+		t.Fatalf("parseZoneAreas returned error: %v", err)
+	}
+	for _, a := range areas {
+		if _, err := time.LoadLocation(a.zone); err != nil {
+			t.Fatalf("unknown zone %s: %v", a.zone, err)
+		}
+	}
+	for _, data := range []string{
+		"Asia/Tokyo\n    24,122.9 45,146",
+		"    24,122.9 45,146 45,122.9",
+		"Asia/Tokyo 24\n    24,122.9 45,146 45,122.9",
+		"Asia/Tokyo\n    24;122.9 45,146 45,122.9",
+		"Asia/Tokyo\n    94,122.9 45,146 45,122.9",
+		"Asia/Tokyo\n    24,190 45,146 45,122.9",
+	} {
+		if _, err := parseZoneAreas(data); err == nil {
+			t.Fatalf("expected an error for %q", data)
+		}
+	}
+}
+
+func TestParseZoneAreasPrefersSmallestPolygon(t *testing.T) {
+	areas, err := parseZoneAreas(`# An enclave inside a larger zone
+Europe/Paris
+    40,0 40,10 50,10 50,0
+Europe/Monaco
+    44,4 44,6
+    46,6 46,4
+`)
+	if err != nil {
+		t.Fatalf("parseZoneAreas returned error: %v", err)
+	}
+	if len(areas) != 2 || areas[0].zone != "Europe/Monaco" || areas[1].zone != "Europe/Paris" {
+		t.Fatalf("unexpected order: %+v", areas)
+	}
+	for _, tc := range []struct {
+		lat, lon float64
+		want     []bool
+	}{
+		{45, 5, []bool{true, true}},
+		{42, 2, []bool{false, true}},
+		{51, 5, []bool{false, false}},
+	} {
+		for i, a := range areas {
+			if got := a.contains(tc.lat, tc.lon); got != tc.want[i] {
+				t.Fatalf("%s contains(%v, %v) = %v", a.zone, tc.lat, tc.lon, got)
+			}
+		}
+	}
+}
+
+func TestZoneAtFindsZonesOfCities(t *testing.T) {
+	for _, tc := range []struct {
+		lat, lon float64
+		want     string
+	}{
+		{35.68, 139.69, "Asia/Tokyo"},
+		{37.57, 126.98, "Asia/Seoul"},
+		{31.23, 121.47, "Asia/Shanghai"},
+		{28.61, 77.21, "Asia/Kolkata"},
+		{31.55, 74.35, "Asia/Karachi"},
+		{34.53, 69.17, "Asia/Kabul"},
+		{27.72, 85.32, "Asia/Kathmandu"},
+		{13.75, 100.50, "Asia/Bangkok"},
+		{10.82, 106.63, "Asia/Ho_Chi_Minh"},
+		{39.47, 75.99, "Asia/Shanghai"},
+		{41.01, 28.98, "Europe/Istanbul"},
+		{48.86, 2.35, "Europe/Paris"},
+		{51.51, -0.13, "Europe/London"},
+		{38.72, -9.14, "Europe/Lisbon"},
+		{65.58, 22.15, "Europe/Stockholm"},
+		{60.17, 24.94, "Europe/Helsinki"},
+		{59.94, 30.31, "Europe/Moscow"},
+		{59.365, 28.23, "Europe/Moscow"},
+		{54.78, 32.05, "Europe/Moscow"},
+		{49.84, 24.03, "Europe/Kyiv"},
+		{40.71, -74.01, "America/New_York"},
+		{39.74, -104.99, "America/Denver"},
+		{33.45, -112.07, "America/Phoenix"},
+		{21.31, -157.86, "Pacific/Honolulu"},
+		{19.43, -99.13, "America/Mexico_City"},
+		{-33.45, -70.67, "America/Santiago"},
+		{-32.89, -68.83, "America/Argentina/Buenos_Aires"},
+		{-18.01, -70.25, "America/Lima"},
+		{-33.87, 151.21, "Australia/Sydney"},
+		{-1.29, 36.82, "Africa/Nairobi"},
+	} {
+		loc := zoneAt(tc.lat, tc.lon)
+		if loc == nil || loc.String() != tc.want {
+			t.Errorf("zoneAt(%v, %v) = %v, want %s", tc.lat, tc.lon, loc, tc.want)
+		}
+	}
+	if loc := zoneAt(30, -40); loc != nil {
+		t.Fatalf("expected no zone in the middle of the Atlantic, got %v", loc)
+	}
+}
+
+func TestParseGPSCoordinate(t *testing.T) {
+	if got, ok := parseGPSCoordinate("35 39 29.16", "N", 90); !ok || got < 35.6580 || got > 35.6582 {
+		t.Fatalf("unexpected latitude %v, %v", got, ok)
+	}
+	if got, ok := parseGPSCoordinate("70 15 0", "W", 180); !ok || got != -70.25 {
+		t.Fatalf("unexpected western longitude %v, %v", got, ok)
+	}
+	for _, tc := range [][2]string{{"91 0 0", "N"}, {"35 39", "N"}, {"35 x 0", "N"}, {"35 0 0", "Q"}} {
+		if _, ok := parseGPSCoordinate(tc[0], tc[1], 90); ok {
+			t.Fatalf("expected %q %q to be rejected", tc[0], tc[1])
+		}
+	}
+}
+
+func TestExifResolverReadsNaiveTimeInGPSZone(t *testing.T) {
+	dir := t.TempDir()
+	dto := asciiEntry(0x9003, "2024:06:01 14:22:33")
+	tokyo := []tiffEntry{
+		asciiEntry(0x0001, "N"), rationalEntry(0x0002, [2]uint32{35, 1}, [2]uint32{39, 1}, [2]uint32{2916, 100}),
+		asciiEntry(0x0003, "E"), rationalEntry(0x0004, [2]uint32{139, 1}, [2]uint32{41, 1}, [2]uint32{30, 1}),
+	}
+	noFix := []tiffEntry{
+		asciiEntry(0x0001, "N"), rationalEntry(0x0002, [2]uint32{0, 1}, [2]uint32{0, 1}, [2]uint32{0, 1}),
+		asciiEntry(0x0003, "E"), rationalEntry(0x0004, [2]uint32{0, 1}, [2]uint32{0, 1}, [2]uint32{0, 1}),
+	}
+	files := map[string][]byte{
+		"tokyo.jpg":  buildJPEG(buildTIFF(nil, []tiffEntry{dto}, tokyo)),
+		"tokyo.nef":  buildTIFF([]tiffEntry{asciiEntry(0x010f, "NIKON CORPORATION")}, []tiffEntry{dto}, tokyo),
+		"no-fix.jpg": buildJPEG(buildTIFF(nil, []tiffEntry{dto}, noFix)),
+		"no-gps.jpg": buildJPEG(buildTIFF(nil, []tiffEntry{dto}, nil)),
+		"offset.jpg": buildJPEG(buildTIFF(nil, []tiffEntry{dto, asciiEntry(0x9011, "+02:00")}, tokyo)),
+	}
+	for name, content := range files {
+		mustWriteBytes(t, filepath.Join(dir, name), content)
+	}
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	resolve := func(name string) Resolution {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat failed: %v", err)
+		}
+		res, err := exifResolver{}.Resolve(context.Background(), &SourceFile{Path: path, Info: fi, Location: paris})
+		if err != nil {
+			t.Fatalf("Resolve returned error: %v", err)
+		}
+		return res
+	}
+
+	want := time.Date(2024, 6, 1, 5, 22, 33, 0, time.UTC)
+	for _, name := range []string{"tokyo.jpg", "tokyo.nef"} {
+		res := resolve(name)
+		if !res.Timestamp.Equal(want) || res.Confidence != 0.95 ||
+			res.Reason != "EXIF DateTimeOriginal without an offset, read as Asia/Tokyo time from the GPS position" {
+			t.Fatalf("%s: unexpected resolution: %+v", name, res)
+		}
+	}
+	// Without a usable position the --tz zone applies
+	for _, name := range []string{"no-fix.jpg", "no-gps.jpg"} {
+		res := resolve(name)
+		if !res.Timestamp.Equal(time.Date(2024, 6, 1, 12, 22, 33, 0, time.UTC)) || res.Confidence != 0.9 ||
+			res.Reason != "EXIF DateTimeOriginal without an offset, read as Europe/Paris time" {
+			t.Fatalf("%s: unexpected resolution: %+v", name, res)
+		}
+	}
+	// A recorded offset wins over the position
+	if res := resolve("offset.jpg"); !res.Timestamp.Equal(time.Date(2024, 6, 1, 12, 22, 33, 0, time.UTC)) || res.Confidence != 1 {
+		t.Fatalf("unexpected resolution with an offset: %+v", res)
+	}
+}
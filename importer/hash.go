@@ -0,0 +1,59 @@
+package importer
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+)
+
+// Hash algorithms accepted by --verify-hash
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+}
+
+const DefaultHashAlgorithm = "sha256"
+
+// Create a hash for the named algorithm
+func newHash(algorithm string) (hash.Hash, error) {
+	newFn, ok := hashAlgorithms[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %q (use sha256, sha512, sha1, md5 or crc32)", algorithm)
+	}
+	return newFn(), nil
+}
+
+// Hash the contents of a file with SHA-256 and return the hex digest
+func hashFile(ctx context.Context, path string) (string, error) {
+	return hashFileWith(ctx, path, DefaultHashAlgorithm)
+}
+
+// Hash the contents of a file with the given algorithm and return the hex digest. Reading
+// stops once ctx is done.
+func hashFileWith(ctx context.Context, path, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, contextReader{ctx, f}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
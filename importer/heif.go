@@ -0,0 +1,328 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Largest box payload read into memory while looking for the Exif item, and largest Exif item
+const (
+	maxHEIFBoxSize  = 1 << 20
+	maxHEIFExifSize = 4 << 20
+)
+
+// ftyp brands of HEIF images, including AVIF which shares the container
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "mif1": true, "msf1": true, "avif": true,
+}
+
+// isoBox is a box of an ISO base media file: its type and where its payload lies
+type isoBox struct {
+	typ        string
+	start, end int64
+}
+
+// Read the header of the box at off. A box never extends beyond limit.
+func readISOBox(r io.ReaderAt, off, limit int64) (isoBox, error) {
+	var hdr [16]byte
+	if _, err := r.ReadAt(hdr[:8], off); err != nil {
+		return isoBox{}, err
+	}
+	size := int64(binary.BigEndian.Uint32(hdr[:4]))
+	b := isoBox{typ: string(hdr[4:8]), start: off + 8}
+	switch size {
+	case 0:
+		// The last box of the file
+		size = limit - off
+	case 1:
+		if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+			return isoBox{}, err
+		}
+		size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+		b.start += 8
+	}
+	b.end = off + size
+	if b.end < b.start || b.end > limit {
+		return isoBox{}, fmt.Errorf("invalid %q box at offset %d", b.typ, off)
+	}
+	return b, nil
+}
+
+// Find the first box of type typ between start and end
+func findISOBox(r io.ReaderAt, start, end int64, typ string) (isoBox, error) {
+	for off := start; off+8 <= end; {
+		b, err := readISOBox(r, off, end)
+		if err != nil {
+			return isoBox{}, err
+		}
+		if b.typ == typ {
+			return b, nil
+		}
+		off = b.end
+	}
+	return isoBox{}, fmt.Errorf("no %s box", typ)
+}
+
+// Read the payload of a box, refusing boxes larger than max
+func readISOPayload(r io.ReaderAt, b isoBox, max int64) ([]byte, error) {
+	if b.end-b.start > max {
+		return nil, fmt.Errorf("%s box too large (%d bytes)", b.typ, b.end-b.start)
+	}
+	payload := make([]byte, b.end-b.start)
+	if _, err := r.ReadAt(payload, b.start); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// boxFields reads the big-endian fields of a box payload. Reading past the end yields zeros
+// and sets short.
+type boxFields struct {
+	b     []byte
+	short bool
+}
+
+// Read an unsigned integer of n bytes, n may be 0
+func (f *boxFields) uint(n int) uint64 {
+	if len(f.b) < n {
+		f.b, f.short = nil, true
+		return 0
+	}
+	var v uint64
+	for _, c := range f.b[:n] {
+		v = v<<8 | uint64(c)
+	}
+	f.b = f.b[n:]
+	return v
+}
+
+func (f *boxFields) str(n int) string {
+	if len(f.b) < n {
+		f.b, f.short = nil, true
+		return ""
+	}
+	s := string(f.b[:n])
+	f.b = f.b[n:]
+	return s
+}
+
+// Report whether the file starts with the ftyp box of a HEIF image
+func isHEIF(r io.ReaderAt, size int64) bool {
+	ftyp, err := readISOBox(r, 0, size)
+	if err != nil || ftyp.typ != "ftyp" {
+		return false
+	}
+	payload, err := readISOPayload(r, ftyp, 4096)
+	if err != nil || len(payload) < 8 {
+		return false
+	}
+	// Major brand, minor version, then compatible brands
+	if heifBrands[string(payload[:4])] {
+		return true
+	}
+	for i := 8; i+4 <= len(payload); i += 4 {
+		if heifBrands[string(payload[i:i+4])] {
+			return true
+		}
+	}
+	return false
+}
+
+// errNoHEIFExif is returned for HEIF files without an Exif item
+var errNoHEIFExif = errors.New("no Exif item in HEIF file")
+
+// Extract the TIFF-formatted EXIF block of a HEIF image. The meta box lists the items of the
+// file in iinf, iloc tells where each item's data is, and the Exif item starts with the offset
+// of the TIFF header within it.
+func extractHEIFExif(r io.ReaderAt, size int64) ([]byte, error) {
+	meta, err := findISOBox(r, 0, size, "meta")
+	if err != nil {
+		return nil, err
+	}
+	// meta is a full box, its children follow the version and flags
+	iinf, err := findISOBox(r, meta.start+4, meta.end, "iinf")
+	if err != nil {
+		return nil, err
+	}
+	itemID, err := heifExifItemID(r, iinf)
+	if err != nil {
+		return nil, err
+	}
+	iloc, err := findISOBox(r, meta.start+4, meta.end, "iloc")
+	if err != nil {
+		return nil, err
+	}
+	payload, err := readISOPayload(r, iloc, maxHEIFBoxSize)
+	if err != nil {
+		return nil, err
+	}
+	extents, method, err := heifItemExtents(payload, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Construction method 0 points into the file, 1 into the idat box of meta
+	base, end := int64(0), size
+	switch method {
+	case 0:
+	case 1:
+		idat, err := findISOBox(r, meta.start+4, meta.end, "idat")
+		if err != nil {
+			return nil, err
+		}
+		base, end = idat.start, idat.end
+	default:
+		return nil, fmt.Errorf("unsupported iloc construction method %d", method)
+	}
+	var data []byte
+	for _, e := range extents {
+		// Corrupt files may point past their end
+		if e[0] > end-base {
+			return nil, fmt.Errorf("Exif item extent outside the file")
+		}
+		// A length of 0 reaches to the end of the file or idat box
+		if e[1] == 0 {
+			e[1] = end - base - e[0]
+		}
+		if e[1] > end-base-e[0] {
+			return nil, fmt.Errorf("Exif item extent outside the file")
+		}
+		if e[1] > maxHEIFExifSize || int64(len(data))+e[1] > maxHEIFExifSize {
+			return nil, fmt.Errorf("Exif item too large")
+		}
+		chunk := make([]byte, e[1])
+		if _, err := r.ReadAt(chunk, base+e[0]); err != nil {
+			return nil, fmt.Errorf("read Exif item: %w", err)
+		}
+		data = append(data, chunk...)
+	}
+
+	if len(data) < 4 {
+		return nil, fmt.Errorf("Exif item too short")
+	}
+	skip := int64(binary.BigEndian.Uint32(data[:4]))
+	if 4+skip >= int64(len(data)) {
+		return nil, fmt.Errorf("invalid TIFF header offset %d in Exif item", skip)
+	}
+	tiff := data[4+skip:]
+	if !bytes.HasPrefix(tiff, []byte("II*\x00")) && !bytes.HasPrefix(tiff, []byte("MM\x00*")) {
+		return nil, fmt.Errorf("no TIFF header in Exif item")
+	}
+	return tiff, nil
+}
+
+// Find the ID of the item of type Exif in the iinf box
+func heifExifItemID(r io.ReaderAt, iinf isoBox) (uint64, error) {
+	head, err := readISOPayload(r, isoBox{typ: iinf.typ, start: iinf.start, end: min(iinf.start+8, iinf.end)}, 8)
+	if err != nil {
+		return 0, err
+	}
+	f := &boxFields{b: head}
+	version := f.uint(1)
+	f.uint(3)
+	countSize := 4
+	if version == 0 {
+		countSize = 2
+	}
+	count := f.uint(countSize)
+	if f.short {
+		return 0, fmt.Errorf("iinf box too short")
+	}
+
+	off := iinf.start + 4 + int64(countSize)
+	for i := uint64(0); i < count && off < iinf.end; i++ {
+		infe, err := readISOBox(r, off, iinf.end)
+		if err != nil {
+			return 0, err
+		}
+		off = infe.end
+		if infe.typ != "infe" {
+			continue
+		}
+		payload, err := readISOPayload(r, infe, maxHEIFBoxSize)
+		if err != nil {
+			return 0, err
+		}
+		f := &boxFields{b: payload}
+		version := f.uint(1)
+		f.uint(3)
+		// Only versions 2 and 3 carry an item type
+		if version < 2 {
+			continue
+		}
+		idSize := 2
+		if version == 3 {
+			idSize = 4
+		}
+		id := f.uint(idSize)
+		f.uint(2) // item_protection_index
+		if typ := f.str(4); !f.short && typ == "Exif" {
+			return id, nil
+		}
+	}
+	return 0, errNoHEIFExif
+}
+
+// Return the extents (offset, length) and construction method of an item from the payload of
+// the iloc box. A length of 0 means the extent reaches to the end of its data.
+func heifItemExtents(payload []byte, itemID uint64) ([][2]int64, uint64, error) {
+	f := &boxFields{b: payload}
+	version := f.uint(1)
+	f.uint(3)
+	sizes := f.uint(2)
+	offsetSize, lengthSize := int(sizes>>12&0xf), int(sizes>>8&0xf)
+	baseOffsetSize, indexSize := int(sizes>>4&0xf), int(sizes&0xf)
+	if version == 0 {
+		indexSize = 0
+	}
+	if version > 2 {
+		return nil, 0, fmt.Errorf("unsupported iloc version %d", version)
+	}
+	idSize, countSize := 2, 2
+	if version == 2 {
+		idSize, countSize = 4, 4
+	}
+
+	count := f.uint(countSize)
+	for i := uint64(0); i < count && !f.short; i++ {
+		id := f.uint(idSize)
+		var method uint64
+		if version > 0 {
+			method = f.uint(2) & 0xf
+		}
+		f.uint(2) // data_reference_index
+		base := int64(f.uint(baseOffsetSize))
+		extentCount := f.uint(2)
+		// Every extent takes bytes of the box, so a corrupt count cannot make this spin
+		extentSize := uint64(indexSize + offsetSize + lengthSize)
+		if extentCount > 0 && extentSize == 0 {
+			return nil, 0, fmt.Errorf("iloc extents without offset or length")
+		}
+		if extentCount*extentSize > uint64(len(f.b)) {
+			return nil, 0, fmt.Errorf("iloc box too short")
+		}
+		extents := make([][2]int64, 0, min(extentCount, 16))
+		for j := uint64(0); j < extentCount && !f.short; j++ {
+			f.uint(indexSize)
+			offset := int64(f.uint(offsetSize))
+			length := int64(f.uint(lengthSize))
+			// 8-byte fields above the int64 range turn negative
+			if id == itemID && (base < 0 || offset < 0 || length < 0 || offset > math.MaxInt64-base) {
+				return nil, 0, fmt.Errorf("invalid extent of Exif item %d in iloc box", itemID)
+			}
+			extents = append(extents, [2]int64{base + offset, length})
+		}
+		if id == itemID && !f.short {
+			return extents, method, nil
+		}
+	}
+	if f.short {
+		return nil, 0, fmt.Errorf("iloc box too short")
+	}
+	return nil, 0, fmt.Errorf("no location for Exif item %d", itemID)
+}
@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func isoBoxBytes(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, typ...), body...)
+}
+
+// buildHEIF wraps tiff in a minimal HEIF file with an image item and an Exif item. With
+// inIdat the Exif item is stored in the idat box of meta instead of mdat.
+func buildHEIF(tiff []byte, inIdat bool) []byte {
+	u16 := func(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+	ftyp := isoBoxBytes("ftyp", []byte("heic"), u32(0), []byte("mif1heic"))
+	iinf := isoBoxBytes("iinf", []byte{0, 0, 0, 0}, u16(2),
+		isoBoxBytes("infe", []byte{2, 0, 0, 0}, u16(1), u16(0), []byte("hvc1\x00")),
+		isoBoxBytes("infe", []byte{2, 0, 0, 0}, u16(2), u16(0), []byte("Exif\x00")),
+	)
+	image := []byte("not really HEVC")
+	exifItem := append(u32(6), append([]byte("Exif\x00\x00"), tiff...)...)
+
+	// iloc version 1 with 4-byte offsets and lengths and no base offset
+	iloc := func(imageOffset, exifOffset uint32) []byte {
+		method := uint16(0)
+		if inIdat {
+			method = 1
+		}
+		return isoBoxBytes("iloc", []byte{1, 0, 0, 0}, u16(0x4400), u16(2),
+			u16(1), u16(0), u16(0), u16(1), u32(imageOffset), u32(uint32(len(image))),
+			u16(2), u16(method), u16(0), u16(1), u32(exifOffset), u32(uint32(len(exifItem))),
+		)
+	}
+	var idat []byte
+	if inIdat {
+		idat = isoBoxBytes("idat", exifItem)
+	}
+	metaSize := len(isoBoxBytes("meta", []byte{0, 0, 0, 0}, iinf, iloc(0, 0), idat))
+	mdatStart := uint32(len(ftyp) + metaSize + 8)
+	exifOffset := mdatStart + uint32(len(image))
+	mdat := isoBoxBytes("mdat", image, exifItem)
+	if inIdat {
+		exifOffset = 0
+		mdat = isoBoxBytes("mdat", image)
+	}
+	meta := isoBoxBytes("meta", []byte{0, 0, 0, 0}, iinf, iloc(mdatStart, exifOffset), idat)
+	return bytes.Join([][]byte{ftyp, meta, mdat}, nil)
+}
+
+func TestExtractHEIFExifFindsExifItem(t *testing.T) {
+	tiff := buildTIFF(nil, []tiffEntry{asciiEntry(0x9003, "2024:06:01 14:22:33")}, nil)
+	for _, inIdat := range []bool{false, true} {
+		heif := buildHEIF(tiff, inIdat)
+		r := bytes.NewReader(heif)
+		if !isHEIF(r, int64(len(heif))) {
+			t.Fatal("expected the fixture to be recognized as HEIF")
+		}
+		got, err := extractHEIFExif(r, int64(len(heif)))
+		if err != nil {
+			t.Fatalf("extractHEIFExif (idat=%v) returned error: %v", inIdat, err)
+		}
+		if !bytes.Equal(got, tiff) {
+			t.Fatalf("extractHEIFExif (idat=%v) returned %d bytes, want the %d byte TIFF block", inIdat, len(got), len(tiff))
+		}
+	}
+
+	jpeg := buildJPEG(tiff)
+	if isHEIF(bytes.NewReader(jpeg), int64(len(jpeg))) {
+		t.Fatal("expected a JPEG not to be recognized as HEIF")
+	}
+	noExif := isoBoxBytes("meta", []byte{0, 0, 0, 0}, isoBoxBytes("iinf", []byte{0, 0, 0, 0}, []byte{0, 0}))
+	if _, err := extractHEIFExif(bytes.NewReader(noExif), int64(len(noExif))); !errors.Is(err, errNoHEIFExif) {
+		t.Fatalf("expected errNoHEIFExif, got: %v", err)
+	}
+}
+
+func TestExtractHEIFExifRejectsInvalidExtents(t *testing.T) {
+	u16 := func(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	u64 := func(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+	iinf := isoBoxBytes("iinf", []byte{0, 0, 0, 0}, u16(1),
+		isoBoxBytes("infe", []byte{2, 0, 0, 0}, u16(1), u16(0), []byte("Exif\x00")),
+	)
+	for name, iloc := range map[string][]byte{
+		// 4-byte offset and 8-byte length with the top bit set
+		"negative length": isoBoxBytes("iloc", []byte{1, 0, 0, 0}, u16(0x4800), u16(1),
+			u16(1), u16(0), u16(0), u16(1), u32(0), u64(1<<63|0x10)),
+		// 8-byte offset with the top bit set
+		"negative offset": isoBoxBytes("iloc", []byte{1, 0, 0, 0}, u16(0x8400), u16(1),
+			u16(1), u16(0), u16(0), u16(1), u64(1<<63), u32(0x10)),
+		"past the end": isoBoxBytes("iloc", []byte{1, 0, 0, 0}, u16(0x4400), u16(1),
+			u16(1), u16(0), u16(0), u16(1), u32(16), u32(1<<20)),
+	} {
+		heif := bytes.Join([][]byte{
+			isoBoxBytes("ftyp", []byte("heic"), u32(0), []byte("mif1heic")),
+			isoBoxBytes("meta", []byte{0, 0, 0, 0}, iinf, iloc),
+		}, nil)
+		if _, err := extractHEIFExif(bytes.NewReader(heif), int64(len(heif))); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestExtractHEIFExifRejectsExtentsWithoutFields(t *testing.T) {
+	u16 := func(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	iinf := isoBoxBytes("iinf", []byte{0, 0, 0, 0}, u16(1),
+		isoBoxBytes("infe", []byte{2, 0, 0, 0}, u16(9), u16(0), []byte("Exif\x00")),
+	)
+	// Items with 65535 extents of zero bytes each, none of them the Exif item
+	var items [][]byte
+	for id := uint16(1); id <= 64; id++ {
+		items = append(items, u16(id), u16(0), u16(0), u16(0xffff))
+	}
+	iloc := isoBoxBytes("iloc", append([]byte{1, 0, 0, 0, 0, 0}, u16(64)...), bytes.Join(items, nil))
+	heif := bytes.Join([][]byte{
+		isoBoxBytes("ftyp", []byte("heic"), u32(0), []byte("mif1heic")),
+		isoBoxBytes("meta", []byte{0, 0, 0, 0}, iinf, iloc),
+	}, nil)
+	if _, err := extractHEIFExif(bytes.NewReader(heif), int64(len(heif))); err == nil {
+		t.Fatal("expected an error for extents without offset or length")
+	}
+}
+
+func TestExtractHEIFExifReadsZeroLengthExtentToTheEnd(t *testing.T) {
+	tiff := buildTIFF(nil, []tiffEntry{asciiEntry(0x9003, "2024:06:01 14:22:33")}, nil)
+	for _, inIdat := range []bool{false, true} {
+		heif := buildHEIF(tiff, inIdat)
+		// The Exif item's length is the last field of iloc, and its data the last of the file
+		// or idat box
+		i := bytes.Index(heif, []byte("iloc")) - 4
+		end := i + int(binary.BigEndian.Uint32(heif[i:]))
+		copy(heif[end-4:end], []byte{0, 0, 0, 0})
+		got, err := extractHEIFExif(bytes.NewReader(heif), int64(len(heif)))
+		if err != nil {
+			t.Fatalf("extractHEIFExif (idat=%v) returned error: %v", inIdat, err)
+		}
+		if !bytes.Equal(got, tiff) {
+			t.Fatalf("extractHEIFExif (idat=%v) returned %d bytes, want the %d byte TIFF block", inIdat, len(got), len(tiff))
+		}
+	}
+}
+
+func TestResolveTimestampReadsHEIFExif(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_0042.HEIC")
+	tiff := buildTIFF(
+		[]tiffEntry{asciiEntry(0x010f, "Apple"), asciiEntry(0x0110, "iPhone 15")},
+		[]tiffEntry{asciiEntry(0x9003, "2024:06:01 14:22:33"), asciiEntry(0x9011, "-07:00")},
+		nil,
+	)
+	mustWriteBytes(t, path, buildHEIF(tiff, false))
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+
+	meta, err := resolveTimestamp(context.Background(), timestampConfig{}, path, fi, func(string, ...any) {})
+	if err != nil {
+		t.Fatalf("resolveTimestamp returned error: %v", err)
+	}
+	want := time.Date(2024, 6, 1, 14, 22, 33, 0, time.FixedZone("", -7*3600))
+	if meta.Source != TimeSourceExif || !meta.Timestamp.Equal(want) || meta.Reason != "EXIF DateTimeOriginal with OffsetTimeOriginal" {
+		t.Fatalf("expected the HEIF EXIF time with its offset, got: %+v", meta)
+	}
+	if meta.Make != "Apple" || meta.Model != "iPhone 15" {
+		t.Fatalf("expected the camera from the HEIF EXIF, got: %+v", meta)
+	}
+}
@@ -0,0 +1,990 @@
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// Options configure an import. From and To are required, the zero value of every other
+// field is a sensible default.
+type Options struct {
+	From       string
+	To         string
+	Filter     string
+	Start      time.Time
+	End        time.Time
+	MaxWorkers int
+	UseModTime bool
+	Recursive  bool
+	MaxDepth   int
+	Layout     string
+	Rename     string
+	OnConflict string
+	Dedupe     bool
+	Verify     bool
+	VerifyHash string
+	Move       bool
+	DryRun     bool
+	Report     string
+	Resume     bool
+	KeepBackup bool
+	// Mtime sets the mtime of copied files: MtimeSource (the default) keeps that of the
+	// source, MtimeCapture uses the resolved timestamp with its sub-second precision
+	Mtime string
+	// TimeOptions date the files unless UseModTime is set. Start and End should be
+	// midnights in FolderTimeZone.
+	TimeOptions
+
+	// Output receives the log lines of the run, nil discards them
+	Output io.Writer
+	// Progress receives a spinner line that is redrawn while files are processed, nil
+	// disables it
+	Progress io.Writer
+}
+
+// Values of Options.Mtime
+const (
+	MtimeSource  = "source"
+	MtimeCapture = "capture"
+)
+
+// Validate an --mtime value, mapping the empty string to MtimeSource
+func ParseMtime(mtime string) (string, error) {
+	switch mtime {
+	case "":
+		return MtimeSource, nil
+	case MtimeSource, MtimeCapture:
+		return mtime, nil
+	}
+	return "", fmt.Errorf("unknown mtime %q (use %s or %s)", mtime, MtimeSource, MtimeCapture)
+}
+
+// importJob is a single source file queued for import. rel is the path relative to
+// Options.From, which equals the file name unless the import is recursive.
+type importJob struct {
+	rel  string
+	info os.FileInfo
+}
+
+// Names of the places a timestamp can come from
+const (
+	TimeSourceExif     = "exif"
+	TimeSourceCR3      = "cr3"
+	TimeSourceVideo    = "video"
+	TimeSourceXMP      = "xmp"
+	TimeSourceFilename = "filename"
+	TimeSourceModTime  = "modtime"
+)
+
+// FileMeta is what resolveTimestamp learned about a file: when it was taken, which resolver
+// found that time and how sure it is, and, if the metadata had them, which camera and lens
+// were used.
+type FileMeta struct {
+	Timestamp  time.Time
+	Source     string
+	Confidence float64
+	Reason     string
+	Make       string
+	Model      string
+	Lens       string
+	// Serial is the camera's BodySerialNumber
+	Serial string
+	// ClockShift is the correction a ClockRule added to Timestamp
+	ClockShift time.Duration
+}
+
+// Summary counts the outcomes of a run. Conflicts are counted in addition to the outcome of
+// the file, Files holds the result of every processed file in completion order.
+type Summary struct {
+	Processed  int
+	Copied     int
+	Skipped    int
+	Failed     int
+	Conflicts  int
+	Duplicates int
+	Moved      int
+	Resumed    int
+	// Interrupted is set when the run was cancelled before every file was processed
+	Interrupted bool
+	Files       []FileResult
+}
+
+// errChecksumMismatch is returned when a verified copy does not match its source
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// copyOptions tune how copyFile writes the destination
+type copyOptions struct {
+	// verify names the hash algorithm used to check the copy, empty disables verification
+	verify string
+	// digest receives the hex SHA-256 of the copied data when set
+	digest *string
+	// backup is where an existing destination file is moved before it is replaced
+	backup string
+	// mtime replaces the source mtime on the destination when set
+	mtime time.Time
+}
+
+// Count a finished file in the summary
+func (s *Summary) add(r FileResult) {
+	s.Files = append(s.Files, r)
+	if r.Conflict {
+		s.Conflicts++
+	}
+	switch r.Outcome {
+	case OutcomeCopied, OutcomePlanned:
+		s.Copied++
+	case OutcomeMoved:
+		s.Copied++
+		s.Moved++
+	case OutcomeSkipped, OutcomeOutOfRange:
+		s.Skipped++
+	case OutcomeDuplicate:
+		s.Skipped++
+		s.Duplicates++
+	case OutcomeResumed:
+		s.Skipped++
+		s.Resumed++
+	case OutcomeFailed:
+		s.Failed++
+	}
+}
+
+// contextReader fails reads once its context is done, so long copies and scans can be aborted
+// between reads
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// contextReadSeeker is a contextReader for parsers that need to seek
+type contextReadSeeker struct {
+	contextReader
+	s io.Seeker
+}
+
+func (c contextReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return c.s.Seek(offset, whence)
+}
+
+// Copy a file from src to dst
+func copyFile(src, dst string) error {
+	return copyFileWith(context.Background(), src, dst, copyOptions{})
+}
+
+// Copy a file from src to dst with the given options, giving up when ctx is done
+func copyFileWith(ctx context.Context, src, dst string, opts copyOptions) (err error) {
+	sfi, err := os.Stat(src)
+	if err != nil {
+		return
+	}
+	if !sfi.Mode().IsRegular() {
+		// Cannot copy non-regular files (directories, symlinks, devices, etc.)
+		return fmt.Errorf("Non-regular source file %s (%q)", sfi.Name(), sfi.Mode().String())
+	}
+	dfi, err := os.Stat(dst)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+	} else {
+		if !(dfi.Mode().IsRegular()) {
+			return fmt.Errorf("Non-regular destination file %s (%q)", dfi.Name(), dfi.Mode().String())
+		}
+		if os.SameFile(sfi, dfi) {
+			return
+		}
+	}
+	mtime := sfi.ModTime()
+	if !opts.mtime.IsZero() {
+		mtime = opts.mtime
+	}
+	err = copyFileContentsWith(ctx, src, dst, mtime, opts)
+	return
+}
+
+// Copy the contents of the file named src to the file named by dst setting the given mtime. If the
+// destination file exists, all of its contents will be replaced by the contents of the source file.
+func copyFileContents(src, dst string, mtime time.Time) error {
+	return copyFileContentsWith(context.Background(), src, dst, mtime, copyOptions{})
+}
+
+// Copy the contents of src to dst like copyFileContents. The data is written to a hidden temporary
+// file next to dst which is fsynced and renamed into place, so an interrupted copy never leaves a
+// truncated file under the final name. With opts.verify set the source is hashed while copying and
+// the temporary file is read back before the rename; on mismatch it is removed. When ctx is done
+// the copy stops and the temporary file is removed as well.
+func copyFileContentsWith(ctx context.Context, src, dst string, mtime time.Time, opts copyOptions) (err error) {
+	var h hash.Hash
+	if opts.verify != "" {
+		if h, err = newHash(opts.verify); err != nil {
+			return
+		}
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	dir := filepath.Dir(dst)
+	out, err := os.CreateTemp(dir, "."+filepath.Base(dst)+".*"+tempFileSuffix)
+	if err != nil {
+		return
+	}
+	tmp := out.Name()
+	closed := false
+	defer func() {
+		if !closed {
+			cerr := out.Close()
+			if err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+	if err = out.Chmod(0o644); err != nil {
+		return
+	}
+	var sums []io.Writer
+	if h != nil {
+		sums = append(sums, h)
+	}
+	digest := sha256.New()
+	if opts.digest != nil {
+		sums = append(sums, digest)
+	}
+	var r io.Reader = contextReader{ctx, in}
+	if len(sums) > 0 {
+		r = io.TeeReader(r, io.MultiWriter(sums...))
+	}
+	if _, err = io.Copy(out, r); err != nil {
+		return
+	}
+	if err = out.Sync(); err != nil {
+		return
+	}
+	closed = true
+	if err = out.Close(); err != nil {
+		return
+	}
+
+	// Update the timestamps
+	if err = os.Chtimes(tmp, time.Now(), mtime); err != nil {
+		return
+	}
+
+	if h != nil {
+		want := hex.EncodeToString(h.Sum(nil))
+		got, herr := hashFileWith(ctx, tmp, opts.verify)
+		if herr != nil {
+			return herr
+		}
+		if got != want {
+			return fmt.Errorf("%w: %s source %s, destination %s", errChecksumMismatch, opts.verify, want, got)
+		}
+	}
+
+	if opts.backup != "" {
+		if err = os.MkdirAll(filepath.Dir(opts.backup), 0o755); err != nil {
+			return
+		}
+		if err = os.Rename(dst, opts.backup); err != nil && !os.IsNotExist(err) {
+			return
+		}
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		if opts.backup != "" {
+			os.Rename(opts.backup, dst)
+		}
+		return
+	}
+	if opts.digest != nil {
+		*opts.digest = hex.EncodeToString(digest.Sum(nil))
+	}
+	return syncDir(dir)
+}
+
+// tempFileSuffix marks the hidden temporary files written by copyFileContentsWith
+const tempFileSuffix = ".importing"
+
+// Flush a directory so a rename inside it survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Remove temporary files an interrupted run left behind in dir
+func cleanupTempFiles(dir string, logf func(string, ...any)) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, tempFileSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil {
+			logf("Removing leftover temporary file %s failed: %v", path, err)
+		} else {
+			logf("Removed leftover temporary file %s", path)
+		}
+	}
+}
+
+// Find a tag in all IFDs and return the value as a string
+func findTagInAllIfds(index *exif.IfdIndex, tagName string) (string, error) {
+	ifds := index.Ifds
+	for _, ifd := range ifds {
+		results, err := ifd.FindTagWithName(tagName)
+		if err == nil && len(results) > 0 {
+			valueRaw, err := results[0].Value()
+			if err != nil {
+				return "", err
+			}
+			value, ok := valueRaw.(string)
+			if !ok {
+				return "", fmt.Errorf("tag %s is not a string", tagName)
+			}
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("tag not found")
+}
+
+// Find a tag of three rationals in all IFDs and return them in decimal separated by spaces,
+// the empty string when it is missing
+func findRationalsInAllIfds(index *exif.IfdIndex, tagName string) string {
+	for _, ifd := range index.Ifds {
+		results, err := ifd.FindTagWithName(tagName)
+		if err != nil || len(results) == 0 {
+			continue
+		}
+		valueRaw, err := results[0].Value()
+		if err != nil {
+			return ""
+		}
+		rationals, ok := valueRaw.([]exifcommon.Rational)
+		if !ok || len(rationals) != 3 {
+			return ""
+		}
+		parts := make([]string, len(rationals))
+		for i, r := range rationals {
+			parts[i] = formatRational(r.Numerator, r.Denominator)
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+// Outcomes recorded for every processed file
+const (
+	OutcomeCopied     = "copied"
+	OutcomeMoved      = "moved"
+	OutcomePlanned    = "planned"
+	OutcomeSkipped    = "skipped"
+	OutcomeOutOfRange = "out-of-range"
+	OutcomeDuplicate  = "duplicate"
+	OutcomeResumed    = "resumed"
+	OutcomeFailed     = "failed"
+	// OutcomeInterrupted marks a file that was being processed when the run was cancelled.
+	// Nothing was left behind for it and --resume picks it up again.
+	OutcomeInterrupted = "interrupted"
+)
+
+// FileResult describes what happened to a single file
+type FileResult struct {
+	// Source is the absolute path of the source file
+	Source      string
+	Destination string
+	Meta        FileMeta
+	Bytes       int64
+	Duration    time.Duration
+	Outcome     string
+	Err         error
+	Conflict    bool
+	// SHA256 is the digest of the copied data, empty when nothing was copied
+	SHA256 string
+
+	// written is set as soon as the copy landed, even if a later step such as deleting the
+	// source failed
+	written     bool
+	createdDirs []string
+	overwrote   bool
+	backup      string
+}
+
+func processFile(ctx context.Context, cfg Options, planner *destinationPlanner, job importJob, meta FileMeta, logf func(string, ...any)) (FileResult, error) {
+	result := FileResult{Outcome: OutcomeCopied}
+	timestamp := meta.Timestamp
+	folder, name, seq := planner.destination(job, meta)
+	fromFile := filepath.Join(cfg.From, job.rel)
+	toFile := filepath.Join(folder, name)
+	// Files that are skipped or fail give their {seq} number to the next file
+	var claimed bool
+	defer func() {
+		if seq > 0 && !result.written && result.Outcome != OutcomePlanned {
+			released := ""
+			if claimed {
+				released = toFile
+			}
+			planner.releaseSeq(folder, seq, released)
+		}
+	}()
+
+	// Another file of this run may already target the same path, or a previous import left
+	// a file there
+	claimed = planner.claim(toFile)
+	_, statErr := os.Lstat(toFile)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return result, fmt.Errorf("%s: stat destination failed: %w", job.rel, statErr)
+	}
+	if !claimed || statErr == nil {
+		result.Conflict = true
+		display := relativeFolder(cfg.To, toFile)
+		policy, _ := ParseConflictPolicy(cfg.OnConflict)
+		if policy == conflictSkipIfIdentical {
+			if claimed {
+				identical, err := sameContent(ctx, fromFile, toFile)
+				if err != nil {
+					return result, fmt.Errorf("%s: compare with %s failed: %w", job.rel, display, err)
+				}
+				if identical {
+					logf("%s: identical file already exists at %s, skipping", job.rel, display)
+					result.Outcome = OutcomeSkipped
+					return result, nil
+				}
+			}
+			policy = conflictRename
+		}
+		// Files of the same run never overwrite each other
+		if policy == conflictOverwrite && !claimed {
+			policy = conflictRename
+		}
+		switch policy {
+		case conflictSkip:
+			logf("%s: %s already exists, skipping", job.rel, display)
+			result.Outcome = OutcomeSkipped
+			return result, nil
+		case conflictFail:
+			return result, fmt.Errorf("%s: destination %s already exists", job.rel, display)
+		case conflictOverwrite:
+			logf("%s: overwriting existing %s", job.rel, display)
+			result.overwrote = true
+		case conflictRename:
+			alt, err := planner.claimAlternative(folder, name)
+			if err != nil {
+				return result, fmt.Errorf("%s: find free name for %s failed: %w", job.rel, display, err)
+			}
+			logf("%s: %s already exists, importing as %s", job.rel, display, filepath.Base(alt))
+			toFile = alt
+			name = filepath.Base(alt)
+		}
+	}
+	result.Destination = toFile
+
+	if cfg.DryRun {
+		verb := "copy"
+		if cfg.Move && !result.Conflict {
+			verb = "move"
+		}
+		logf("Would %s %s -> %s (%s)", verb, job.rel, relativeFolder(cfg.To, toFile), timestamp.Format("2006-01-02 15:04:05"))
+		result.Outcome = OutcomePlanned
+		return result, nil
+	}
+
+	created, err := mkdirAllTracked(folder)
+	result.createdDirs = created
+	if err != nil {
+		return result, fmt.Errorf("%s: create folder %s failed: %w", job.rel, folder, err)
+	}
+	planner.cleanupFolder(folder, logf)
+	if name != job.info.Name() {
+		logf("Copying %s -> %s/%s (%s)", job.rel, relativeFolder(cfg.To, folder), name, timestamp.Format("2006-01-02 15:04:05"))
+	} else {
+		logf("Copying %s -> %s/ (%s)", job.rel, relativeFolder(cfg.To, folder), timestamp.Format("2006-01-02 15:04:05"))
+	}
+	opts := copyOptions{digest: &result.SHA256}
+	if cfg.Mtime == MtimeCapture {
+		opts.mtime = timestamp
+	}
+	if cfg.Verify || cfg.Move {
+		opts.verify = cfg.VerifyHash
+		if opts.verify == "" {
+			opts.verify = DefaultHashAlgorithm
+		}
+	}
+	if result.overwrote && planner.backupDir != "" {
+		rel, err := filepath.Rel(cfg.To, toFile)
+		if err != nil {
+			return result, fmt.Errorf("%s: locate backup for %s failed: %w", job.rel, toFile, err)
+		}
+		opts.backup = filepath.Join(planner.backupDir, rel)
+		result.backup = opts.backup
+	}
+	if err := copyFileWith(ctx, fromFile, toFile, opts); err != nil {
+		result.backup = ""
+		result.overwrote = false
+		return result, fmt.Errorf("%s: copy failed: %w", job.rel, err)
+	}
+	result.written = true
+
+	if cfg.Move {
+		// Only a clean, verified copy may cost us the original
+		if result.Conflict {
+			logf("%s: destination collided, keeping source", job.rel)
+			return result, nil
+		}
+		if err := os.Remove(fromFile); err != nil {
+			return result, fmt.Errorf("%s: copied but removing source failed: %w", job.rel, err)
+		}
+		result.Outcome = OutcomeMoved
+		logf("Deleted source %s after verified copy", fromFile)
+	}
+	return result, nil
+}
+
+// Describe a destination folder relative to the destination root for log output
+func relativeFolder(root, folder string) string {
+	rel, err := filepath.Rel(root, folder)
+	if err != nil {
+		return folder
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Report whether a file name passes the extension filter
+func matchesFilter(cfg Options, name string) bool {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	return cfg.Filter == "" || ext == cfg.Filter
+}
+
+// Collect the files to import. Without --recursive only the top level of cfg.From is listed,
+// otherwise the tree is walked, skipping hidden directories and anything below cfg.MaxDepth.
+// Files whose info cannot be read are logged and counted in the returned failure count.
+func collectJobs(ctx context.Context, cfg Options, logf func(string, ...any)) ([]importJob, int, error) {
+	var jobs []importJob
+	failed := 0
+	addJob := func(rel string, d fs.DirEntry) {
+		if !matchesFilter(cfg, d.Name()) {
+			return
+		}
+		info, err := d.Info()
+		if err != nil {
+			logf("Error getting info for %s: %v", rel, err)
+			failed++
+			return
+		}
+		jobs = append(jobs, importJob{rel: rel, info: info})
+	}
+
+	if !cfg.Recursive {
+		files, err := os.ReadDir(cfg.From)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			addJob(f.Name(), f)
+		}
+		return jobs, failed, nil
+	}
+
+	err := filepath.WalkDir(cfg.From, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if path == cfg.From {
+				return err
+			}
+			logf("Error reading %s: %v", path, err)
+			failed++
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(cfg.From, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			if cfg.MaxDepth > 0 && strings.Count(rel, string(filepath.Separator)) >= cfg.MaxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		addJob(rel, d)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, failed, nil
+}
+
+func runImport(ctx context.Context, cfg Options, out, progress io.Writer) (Summary, error) {
+	var (
+		mu      sync.Mutex
+		summary Summary
+		current string
+		total   int
+	)
+	logf := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Clear the current spinner line so the log prints on a clean line
+		if progress != nil {
+			fmt.Fprint(progress, "\r\033[2K")
+		}
+		fmt.Fprintf(out, format+"\n", args...)
+	}
+
+	planner, err := newDestinationPlanner(cfg)
+	if err != nil {
+		return Summary{}, err
+	}
+	if _, err := ParseConflictPolicy(cfg.OnConflict); err != nil {
+		return Summary{}, err
+	}
+	if _, err := ParseMtime(cfg.Mtime); err != nil {
+		return Summary{}, err
+	}
+	tc := cfg.timestampConfig()
+	queued, failed, err := collectJobs(ctx, cfg, logf)
+	if err != nil {
+		return Summary{}, err
+	}
+	summary.Failed = failed
+
+	var index *libraryIndex
+	if cfg.Dedupe {
+		index, err = loadLibraryIndex(ctx, cfg.To, logf)
+		if err != nil {
+			return Summary{}, err
+		}
+	}
+
+	absFrom, err := filepath.Abs(cfg.From)
+	if err != nil {
+		return Summary{}, err
+	}
+	journal, err := openJournal(cfg.To, cfg.Resume, cfg.DryRun)
+	if err != nil {
+		return Summary{}, fmt.Errorf("open import journal failed: %w", err)
+	}
+	defer journal.Close()
+
+	runID := newRunID(time.Now())
+	manifest := newRunManifest(cfg, runID)
+	if !cfg.DryRun {
+		if err := manifest.start(); err != nil {
+			return Summary{}, fmt.Errorf("create run manifest failed: %w", err)
+		}
+		defer manifest.close()
+	}
+	planner.seqFromDisk = true
+	if cfg.KeepBackup {
+		planner.backupDir = filepath.Join(cfg.To, metaDirName, backupsDirName, runID)
+	}
+
+	plan := make(map[string]int)
+	var report *runReport
+	if cfg.Report != "" {
+		report = newRunReport(cfg, runID)
+	}
+	if cfg.DryRun {
+		fmt.Fprintf(out, "Dry run: planning import from %s -> %s, nothing will be written\n", cfg.From, cfg.To)
+	} else {
+		fmt.Fprintf(out, "Importing files from %s -> %s (run %s)\n", cfg.From, cfg.To, runID)
+	}
+
+	// Import a single file and describe the outcome
+	importFile := func(job importJob) FileResult {
+		started := time.Now()
+		result := FileResult{Source: filepath.Join(absFrom, job.rel), Bytes: job.info.Size()}
+		finish := func(r FileResult, err error) FileResult {
+			r.Source, r.Bytes, r.Meta = result.Source, result.Bytes, result.Meta
+			r.Duration = time.Since(started)
+			if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+				logf("%s: interrupted", job.rel)
+				r.Outcome = OutcomeInterrupted
+				r.Err = err
+			} else if err != nil {
+				logf("%v", err)
+				r.Outcome = OutcomeFailed
+				r.Err = err
+			}
+			return r
+		}
+
+		if err := ctx.Err(); err != nil {
+			return finish(FileResult{}, err)
+		}
+		if cfg.Resume && journal.done(result.Source, job.info) {
+			return finish(FileResult{Outcome: OutcomeResumed}, nil)
+		}
+
+		if cfg.UseModTime {
+			result.Meta = FileMeta{Timestamp: job.info.ModTime(), Source: TimeSourceModTime, Confidence: modTimeConfidence}
+		} else {
+			meta, err := resolveTimestamp(ctx, tc, result.Source, job.info, logf)
+			if err != nil {
+				return finish(FileResult{}, err)
+			}
+			result.Meta = meta
+		}
+		if result.Meta.Timestamp.Before(cfg.Start) || result.Meta.Timestamp.After(cfg.End) {
+			return finish(FileResult{Outcome: OutcomeOutOfRange}, nil)
+		}
+
+		var sum string
+		if index != nil {
+			var err error
+			sum, err = hashFile(ctx, result.Source)
+			if err != nil {
+				return finish(FileResult{}, fmt.Errorf("%s: hash failed: %w", job.rel, err))
+			}
+			if existing, ok := index.reserve(sum, job.rel); !ok {
+				logf("%s: duplicate of %s, skipping", job.rel, existing)
+				return finish(FileResult{Outcome: OutcomeDuplicate}, nil)
+			}
+		}
+
+		r, err := processFile(ctx, cfg, planner, job, result.Meta, logf)
+		if index != nil {
+			if r.written {
+				if addErr := index.add(r.Destination, sum); addErr != nil {
+					logf("%s: update library index failed: %v", job.rel, addErr)
+				}
+			} else if err != nil || r.Outcome != OutcomePlanned {
+				index.release(sum)
+			}
+		}
+		return finish(r, err)
+	}
+
+	jobs := make(chan importJob)
+	var wg sync.WaitGroup
+	for range cfg.MaxWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				mu.Lock()
+				summary.Processed++
+				current = job.rel
+				mu.Unlock()
+
+				result := importFile(job)
+				if result.Outcome != OutcomeResumed {
+					if err := journal.record(result, job.info); err != nil {
+						logf("%s: update import journal failed: %v", job.rel, err)
+					}
+				}
+
+				mu.Lock()
+				summary.add(result)
+				if result.Outcome == OutcomePlanned {
+					plan[relativeFolder(cfg.To, filepath.Dir(result.Destination))]++
+				}
+				if report != nil {
+					report.add(result)
+				}
+				manifestErr := manifest.add(result)
+				mu.Unlock()
+				if manifestErr != nil {
+					logf("%s: update run manifest failed: %v", job.rel, manifestErr)
+				}
+			}
+		}()
+	}
+
+	total = len(queued)
+	progressDone := make(chan struct{})
+	var progressWg sync.WaitGroup
+	if total > 0 && progress != nil {
+		progressWg.Add(1)
+		go func() {
+			defer progressWg.Done()
+			frames := []byte{'|', '/', '-', '\\'}
+			frameIdx := 0
+			ticker := time.NewTicker(200 * time.Millisecond)
+			defer ticker.Stop()
+
+			truncate := func(s string, max int) string {
+				if len(s) <= max {
+					return s
+				}
+				if max <= 3 {
+					return s[:max]
+				}
+				return "..." + s[len(s)-(max-3):]
+			}
+
+			for {
+				select {
+				case <-ticker.C:
+					mu.Lock()
+					p := summary.Processed
+					c := summary.Copied
+					s := summary.Skipped
+					f := summary.Failed
+					k := summary.Conflicts
+					name := current
+					t := total
+					mu.Unlock()
+
+					if p > t {
+						p = t
+					}
+					line := fmt.Sprintf(
+						"\r\033[2K%c Checking %d/%d (copied %d, skipped %d, failed %d, conflicts %d)",
+						frames[frameIdx%len(frames)],
+						p,
+						t,
+						c,
+						s,
+						f,
+						k,
+					)
+					if name != "" {
+						line += " " + truncate(name, 48)
+					}
+					fmt.Fprint(progress, line)
+					frameIdx++
+				case <-progressDone:
+					fmt.Fprint(progress, "\r\033[2K")
+					return
+				}
+			}
+		}()
+	}
+
+	// Stop handing out files once the run is cancelled, the workers finish or abort the files
+	// they hold
+	runDone, watchDone := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		select {
+		case <-ctx.Done():
+			logf("Interrupted, stopping after the files in progress")
+		case <-runDone:
+		}
+	}()
+dispatch:
+	for _, job := range queued {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(runDone)
+	<-watchDone
+	summary.Interrupted = ctx.Err() != nil
+	close(progressDone)
+	progressWg.Wait()
+
+	if index != nil && !cfg.DryRun {
+		if err := index.save(); err != nil {
+			logf("Saving library index failed: %v", err)
+		}
+	}
+
+	if !cfg.DryRun {
+		manifest.Finished = time.Now()
+		if err := manifest.close(); err != nil {
+			logf("Closing run manifest log failed: %v", err)
+		}
+		if err := manifest.save(); err != nil {
+			logf("Saving run manifest failed: %v", err)
+		}
+	}
+
+	if report != nil {
+		if err := report.write(cfg.Report, summary); err != nil {
+			logf("Writing report %s failed: %v", cfg.Report, err)
+		}
+	}
+
+	if cfg.DryRun {
+		folders := make([]string, 0, len(plan))
+		for folder := range plan {
+			folders = append(folders, folder)
+		}
+		sort.Strings(folders)
+		fmt.Fprintf(out, "Planned destination folders:\n")
+		for _, folder := range folders {
+			fmt.Fprintf(out, "  %s/: %d file(s)\n", folder, plan[folder])
+		}
+	}
+
+	status := "Done"
+	if summary.Interrupted {
+		status = "Interrupted"
+	}
+	fmt.Fprintf(
+		out,
+		"%s. processed=%d copied=%d skipped=%d failed=%d conflicts=%d duplicates=%d moved=%d resumed=%d\n",
+		status,
+		summary.Processed,
+		summary.Copied,
+		summary.Skipped,
+		summary.Failed,
+		summary.Conflicts,
+		summary.Duplicates,
+		summary.Moved,
+		summary.Resumed,
+	)
+
+	if err := ctx.Err(); err != nil {
+		return summary, fmt.Errorf("import interrupted: %w", err)
+	}
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("import completed with %d failures", summary.Failed)
+	}
+	return summary, nil
+}
@@ -0,0 +1,695 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunImportAppliesFilterAndDateRange(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(to, 0o755); err != nil {
+		t.Fatalf("mkdir to failed: %v", err)
+	}
+
+	inRange := filepath.Join(from, "in_range.jpg")
+	outOfRange := filepath.Join(from, "out_range.jpg")
+	filtered := filepath.Join(from, "ignored.txt")
+
+	mustWriteFile(t, inRange, "jpg content")
+	mustWriteFile(t, outOfRange, "older jpg")
+	mustWriteFile(t, filtered, "text content")
+
+	mustSetMtime(t, inRange, time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC))
+	mustSetMtime(t, outOfRange, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	mustSetMtime(t, filtered, time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC))
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		Filter:     "jpg",
+		Start:      time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		End:        time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+
+	if summary.Processed != 2 {
+		t.Fatalf("expected processed=2 (only jpg files), got: %d", summary.Processed)
+	}
+	if summary.Copied != 1 {
+		t.Fatalf("expected copied=1, got: %d", summary.Copied)
+	}
+	if summary.Skipped != 1 {
+		t.Fatalf("expected skipped=1, got: %d", summary.Skipped)
+	}
+	if summary.Failed != 0 {
+		t.Fatalf("expected failed=0, got: %d", summary.Failed)
+	}
+
+	copiedPath := filepath.Join(to, "2024-03-05-jpg", "in_range.jpg")
+	if got := readFileString(t, copiedPath); got != "jpg content" {
+		t.Fatalf("copied file content mismatch: %q", got)
+	}
+
+	notCopiedPath := filepath.Join(to, "2024-03-01-jpg", "out_range.jpg")
+	if _, err := os.Stat(notCopiedPath); !os.IsNotExist(err) {
+		t.Fatalf("expected out-of-range file to not be copied, stat err=%v", err)
+	}
+
+	ignoredPath := filepath.Join(to, "2024-03-05-txt", "ignored.txt")
+	if _, err := os.Stat(ignoredPath); !os.IsNotExist(err) {
+		t.Fatalf("expected filtered file to not be copied, stat err=%v", err)
+	}
+}
+
+func TestRunImportNormalizesExtensionFolderName(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(to, 0o755); err != nil {
+		t.Fatalf("mkdir to failed: %v", err)
+	}
+
+	src := filepath.Join(from, "IMG_0001.JPG")
+	mustWriteFile(t, src, "upper extension")
+	mustSetMtime(t, src, time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC))
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		Filter:     "jpg",
+		Start:      time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC),
+		End:        time.Date(2024, 7, 8, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 1 {
+		t.Fatalf("expected copied=1, got: %d", summary.Copied)
+	}
+
+	dst := filepath.Join(to, "2024-07-08-jpg", "IMG_0001.JPG")
+	if got := readFileString(t, dst); got != "upper extension" {
+		t.Fatalf("copied file content mismatch: %q", got)
+	}
+}
+
+func TestRunImportReturnsErrorForMissingSourceDir(t *testing.T) {
+	root := t.TempDir()
+	cfg := Options{
+		From:       filepath.Join(root, "missing"),
+		To:         filepath.Join(root, "to"),
+		MaxWorkers: 1,
+	}
+
+	var out bytes.Buffer
+	_, err := runImport(context.Background(), cfg, &out, nil)
+	if err == nil {
+		t.Fatal("expected error for missing source directory")
+	}
+}
+
+func TestRunImportWritesProgressToDedicatedWriter(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(to, 0o755); err != nil {
+		t.Fatalf("mkdir to failed: %v", err)
+	}
+
+	src := filepath.Join(from, "progress.jpg")
+	mustWriteFile(t, src, "progress content")
+	mustSetMtime(t, src, time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC))
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		Filter:     "jpg",
+		Start:      time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC),
+		End:        time.Date(2024, 7, 8, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+	}
+
+	var out bytes.Buffer
+	var progress bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, &progress)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 1 {
+		t.Fatalf("expected copied=1, got: %d", summary.Copied)
+	}
+	if strings.Contains(out.String(), "Checking") {
+		t.Fatalf("expected progress output to stay out of main output, got: %q", out.String())
+	}
+	if !strings.Contains(progress.String(), "\033[2K") {
+		t.Fatalf("expected ANSI clearing sequence in progress output, got: %q", progress.String())
+	}
+}
+
+func TestRunImportDoesNotWriteProgressWhenDisabled(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(to, 0o755); err != nil {
+		t.Fatalf("mkdir to failed: %v", err)
+	}
+
+	src := filepath.Join(from, "quiet.jpg")
+	mustWriteFile(t, src, "quiet content")
+	mustSetMtime(t, src, time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC))
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		Filter:     "jpg",
+		Start:      time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC),
+		End:        time.Date(2024, 7, 8, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 1 {
+		t.Fatalf("expected copied=1, got: %d", summary.Copied)
+	}
+	if strings.Contains(out.String(), "Done checking") {
+		t.Fatalf("expected no progress output in main output when progress is disabled, got: %q", out.String())
+	}
+}
+
+func TestRunImportLeavesProgressWriterEmptyWhenNoFilesMatch(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(to, 0o755); err != nil {
+		t.Fatalf("mkdir to failed: %v", err)
+	}
+
+	src := filepath.Join(from, "ignored.txt")
+	mustWriteFile(t, src, "ignored content")
+	mustSetMtime(t, src, time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC))
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		Filter:     "jpg",
+		Start:      time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC),
+		End:        time.Date(2024, 7, 8, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+	}
+
+	var out bytes.Buffer
+	var progress bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, &progress)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Processed != 0 || summary.Copied != 0 || summary.Skipped != 0 || summary.Failed != 0 {
+		t.Fatalf("expected empty summary, got: %+v", summary)
+	}
+	if progress.Len() != 0 {
+		t.Fatalf("expected no progress output when no files match, got: %q", progress.String())
+	}
+	if !strings.Contains(out.String(), "Done. processed=0 copied=0 skipped=0 failed=0") {
+		t.Fatalf("expected final summary in main output, got: %q", out.String())
+	}
+}
+
+func TestRunImportBypassesExifWithFastFlag(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+
+	src := filepath.Join(from, "fake_image.jpg")
+	mustWriteFile(t, src, "this is not a real jpeg")
+	mtime := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+	mustSetMtime(t, src, mtime)
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		Start:      time.Time{}, // 0
+		End:        time.Date(2025, 1, 1, 23, 59, 59, 0, time.UTC), // 20250101
+		MaxWorkers: 1,
+	}
+
+	// 1. Run without --fast (Should attempt EXIF and log the fallback)
+	var outSlow bytes.Buffer
+	cfg.UseModTime = false
+	_, err := runImport(context.Background(), cfg, &outSlow, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v", err)
+	}
+	if !strings.Contains(outSlow.String(), "no EXIF data found, using ModTime") {
+		t.Fatalf("expected standard run to complain about missing EXIF data, but got: %s", outSlow.String())
+	}
+
+	// Clean the target folder for the next test
+	os.RemoveAll(to)
+	if err := os.MkdirAll(to, 0o755); err != nil {
+		t.Fatalf("mkdir to failed: %v", err)
+	}
+
+	// 2. Run with --fast (Should silently bypass EXIF and immediately use ModTime)
+	var outFast bytes.Buffer
+	cfg.UseModTime = true
+	_, err = runImport(context.Background(), cfg, &outFast, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v", err)
+	}
+	if strings.Contains(outFast.String(), "no EXIF data found, using ModTime") {
+		t.Fatalf("expected --fast run to bypass EXIF parsing completely, but got EXIF logs: %s", outFast.String())
+	}
+}
+
+func TestRunImportRecursiveWalksSubdirectories(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	for _, dir := range []string{"DCIM/100CANON", "DCIM/101CANON", "DCIM/.thumbnails"} {
+		if err := os.MkdirAll(filepath.Join(from, dir), 0o755); err != nil {
+			t.Fatalf("mkdir %s failed: %v", dir, err)
+		}
+	}
+
+	mtime := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+	for _, rel := range []string{"DCIM/100CANON/IMG_0001.JPG", "DCIM/101CANON/IMG_0101.JPG", "DCIM/.thumbnails/thumb.jpg"} {
+		path := filepath.Join(from, rel)
+		mustWriteFile(t, path, rel)
+		mustSetMtime(t, path, mtime)
+	}
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+		UseModTime: true,
+		Recursive:  true,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Processed != 2 || summary.Copied != 2 {
+		t.Fatalf("expected 2 processed and copied files, got: %+v", summary)
+	}
+
+	dst := filepath.Join(to, "2024-07-08-jpg", "IMG_0101.JPG")
+	if got := readFileString(t, dst); got != "DCIM/101CANON/IMG_0101.JPG" {
+		t.Fatalf("copied file content mismatch: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(to, "2024-07-08-jpg", "thumb.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("expected hidden directory to be skipped, stat err=%v", err)
+	}
+}
+
+func TestRunImportRecursiveHonorsMaxDepth(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(filepath.Join(from, "a", "b"), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+
+	mtime := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+	for _, rel := range []string{"top.jpg", "a/one.jpg", "a/b/two.jpg"} {
+		path := filepath.Join(from, rel)
+		mustWriteFile(t, path, rel)
+		mustSetMtime(t, path, mtime)
+	}
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+		UseModTime: true,
+		Recursive:  true,
+		MaxDepth:   1,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 2 {
+		t.Fatalf("expected copied=2, got: %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(to, "2024-07-08-jpg", "two.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("expected file below max depth to be skipped, stat err=%v", err)
+	}
+}
+
+func TestRunImportAppliesLayoutWithExifCamera(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+
+	tiff := buildTIFF(
+		[]tiffEntry{asciiEntry(0x010f, "FUJIFILM"), asciiEntry(0x0110, "X-T5")},
+		[]tiffEntry{asciiEntry(0x9003, "2023:05:06 07:08:09")},
+		nil,
+	)
+	mustWriteBytes(t, filepath.Join(from, "DSCF0001.JPG"), buildJPEG(tiff))
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+		Layout:     "{camera}/{yyyy}/{date}",
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 1 {
+		t.Fatalf("expected copied=1, got: %+v", summary)
+	}
+	dst := filepath.Join(to, "FUJIFILM X-T5", "2023", "2023-05-06", "DSCF0001.JPG")
+	if _, err := os.Stat(dst); err != nil {
+		t.Fatalf("expected file at %s: %v\noutput:\n%s", dst, err, out.String())
+	}
+}
+
+func TestRunImportRenameKeepsFilesWithSameNameApart(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	for _, dir := range []string{"body1", "body2"} {
+		if err := os.MkdirAll(filepath.Join(from, dir), 0o755); err != nil {
+			t.Fatalf("mkdir %s failed: %v", dir, err)
+		}
+	}
+
+	mtime := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+	for _, rel := range []string{"body1/IMG_0001.JPG", "body2/IMG_0001.JPG"} {
+		path := filepath.Join(from, rel)
+		mustWriteFile(t, path, rel)
+		mustSetMtime(t, path, mtime)
+	}
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+		UseModTime: true,
+		Recursive:  true,
+		Rename:     "{yyyyMMdd}_{seq:3}_{orig}",
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 2 {
+		t.Fatalf("expected copied=2, got: %+v", summary)
+	}
+
+	folder := filepath.Join(to, "2024-07-08-jpg")
+	contents := map[string]bool{}
+	for _, name := range []string{"20240708_001_IMG_0001.JPG", "20240708_002_IMG_0001.JPG"} {
+		contents[readFileString(t, filepath.Join(folder, name))] = true
+	}
+	if !contents["body1/IMG_0001.JPG"] || !contents["body2/IMG_0001.JPG"] {
+		t.Fatalf("expected both source files to be kept, got: %v", contents)
+	}
+
+	// A later import into the same folder continues the counter
+	later := filepath.Join(root, "later")
+	if err := os.MkdirAll(later, 0o755); err != nil {
+		t.Fatalf("mkdir later failed: %v", err)
+	}
+	mustWriteFile(t, filepath.Join(later, "IMG_0002.JPG"), "later")
+	mustSetMtime(t, filepath.Join(later, "IMG_0002.JPG"), mtime)
+	cfg.From = later
+	out.Reset()
+	if _, err := runImport(context.Background(), cfg, &out, nil); err != nil {
+		t.Fatalf("second runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if got := readFileString(t, filepath.Join(folder, "20240708_003_IMG_0002.JPG")); got != "later" {
+		t.Fatalf("expected the second import to take number 003, got: %q", got)
+	}
+}
+
+func TestRunImportRenameReusesNumberOfFailedFile(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	// a.jpg fails to copy, b.jpg then takes its number and its path
+	if err := os.Symlink(filepath.Join(root, "missing.jpg"), filepath.Join(from, "a.jpg")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	mustWriteFile(t, filepath.Join(from, "b.jpg"), "b")
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+		UseModTime: true,
+		Layout:     "{ext}",
+		Rename:     "{seq:3}",
+	}
+	var out bytes.Buffer
+	summary, _ := runImport(context.Background(), cfg, &out, nil)
+	if summary.Failed != 1 || summary.Copied != 1 || summary.Conflicts != 0 {
+		t.Fatalf("expected one failure, one copy and no conflicts, got: %+v\noutput:\n%s", summary, out.String())
+	}
+	if got := readFileString(t, filepath.Join(to, "jpg", "001.jpg")); got != "b" {
+		t.Fatalf("expected b.jpg to take number 001, got: %q", got)
+	}
+}
+
+func TestRunImportConflictPolicies(t *testing.T) {
+	cases := []struct {
+		policy      string
+		existing    string
+		wantFiles   map[string]string
+		wantSkipped int
+		wantFailed  int
+	}{
+		{conflictSkip, "old", map[string]string{"a.jpg": "old"}, 1, 0},
+		{conflictOverwrite, "old", map[string]string{"a.jpg": "new"}, 0, 0},
+		{conflictRename, "old", map[string]string{"a.jpg": "old", "a-1.jpg": "new"}, 0, 0},
+		{conflictSkipIfIdentical, "new", map[string]string{"a.jpg": "new"}, 1, 0},
+		{conflictSkipIfIdentical, "old", map[string]string{"a.jpg": "old", "a-1.jpg": "new"}, 0, 0},
+		{conflictFail, "old", map[string]string{"a.jpg": "old"}, 0, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.policy+"-"+tc.existing, func(t *testing.T) {
+			root := t.TempDir()
+			from := filepath.Join(root, "from")
+			folder := filepath.Join(root, "to", "2024-07-08-jpg")
+			if err := os.MkdirAll(from, 0o755); err != nil {
+				t.Fatalf("mkdir from failed: %v", err)
+			}
+			if err := os.MkdirAll(folder, 0o755); err != nil {
+				t.Fatalf("mkdir folder failed: %v", err)
+			}
+			src := filepath.Join(from, "a.jpg")
+			mustWriteFile(t, src, "new")
+			mustSetMtime(t, src, time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC))
+			mustWriteFile(t, filepath.Join(folder, "a.jpg"), tc.existing)
+
+			cfg := Options{
+				From:       from,
+				To:         filepath.Join(root, "to"),
+				End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+				MaxWorkers: 1,
+				UseModTime: true,
+				OnConflict: tc.policy,
+			}
+
+			var out bytes.Buffer
+			summary, err := runImport(context.Background(), cfg, &out, nil)
+			if (err != nil) != (tc.wantFailed > 0) {
+				t.Fatalf("unexpected runImport error: %v\noutput:\n%s", err, out.String())
+			}
+			if summary.Conflicts != 1 || summary.Skipped != tc.wantSkipped || summary.Failed != tc.wantFailed {
+				t.Fatalf("unexpected summary: %+v", summary)
+			}
+			entries, err := os.ReadDir(folder)
+			if err != nil {
+				t.Fatalf("read folder failed: %v", err)
+			}
+			if len(entries) != len(tc.wantFiles) {
+				t.Fatalf("expected %d files, got %d", len(tc.wantFiles), len(entries))
+			}
+			for name, content := range tc.wantFiles {
+				if got := readFileString(t, filepath.Join(folder, name)); got != content {
+					t.Fatalf("%s: expected %q, got %q", name, content, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRunImportMoveDeletesOnlyCleanlyCopiedSources(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	folder := filepath.Join(to, "2024-07-08-jpg")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		t.Fatalf("mkdir folder failed: %v", err)
+	}
+
+	mtime := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+	moved := filepath.Join(from, "moved.jpg")
+	collided := filepath.Join(from, "collided.jpg")
+	mustWriteFile(t, moved, "moved content")
+	mustWriteFile(t, collided, "new content")
+	mustSetMtime(t, moved, mtime)
+	mustSetMtime(t, collided, mtime)
+	mustWriteFile(t, filepath.Join(folder, "collided.jpg"), "old content")
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+		UseModTime: true,
+		Move:       true,
+		OnConflict: conflictRename,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 2 || summary.Moved != 1 {
+		t.Fatalf("expected two copies and one move, got: %+v", summary)
+	}
+	if _, err := os.Stat(moved); !os.IsNotExist(err) {
+		t.Fatalf("expected moved source to be deleted, stat err=%v", err)
+	}
+	if got := readFileString(t, filepath.Join(folder, "moved.jpg")); got != "moved content" {
+		t.Fatalf("moved file content mismatch: %q", got)
+	}
+	if got := readFileString(t, collided); got != "new content" {
+		t.Fatalf("expected collided source to be kept, got: %q", got)
+	}
+	if !strings.Contains(out.String(), "Deleted source "+moved) {
+		t.Fatalf("expected deletion to be logged, got: %s", out.String())
+	}
+}
+
+func TestRunImportDryRunPlansWithoutWriting(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	folder := filepath.Join(to, "2024-07-08-jpg")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		t.Fatalf("mkdir folder failed: %v", err)
+	}
+
+	for name, mtime := range map[string]time.Time{
+		"a.jpg": time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC),
+		"b.jpg": time.Date(2024, 7, 8, 10, 0, 0, 0, time.UTC),
+		"c.jpg": time.Date(2024, 7, 9, 10, 0, 0, 0, time.UTC),
+	} {
+		path := filepath.Join(from, name)
+		mustWriteFile(t, path, name)
+		mustSetMtime(t, path, mtime)
+	}
+	mustWriteFile(t, filepath.Join(folder, "b.jpg"), "existing")
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+		UseModTime: true,
+		OnConflict: conflictRename,
+		DryRun:     true,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 3 || summary.Conflicts != 1 {
+		t.Fatalf("expected three planned copies and one conflict, got: %+v", summary)
+	}
+	for _, want := range []string{
+		"Would copy a.jpg -> 2024-07-08-jpg/a.jpg",
+		"Would copy b.jpg -> 2024-07-08-jpg/b-1.jpg",
+		"2024-07-08-jpg/: 2 file(s)",
+		"2024-07-09-jpg/: 1 file(s)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out.String())
+		}
+	}
+
+	entries, err := os.ReadDir(to)
+	if err != nil {
+		t.Fatalf("read destination failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected dry run to create nothing, got %d entries", len(entries))
+	}
+	if got := readFileString(t, filepath.Join(folder, "b.jpg")); got != "existing" {
+		t.Fatalf("expected existing file to be untouched, got: %q", got)
+	}
+}
@@ -0,0 +1,438 @@
+package importer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustWriteFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("write %s failed: %v", path, err)
+	}
+}
+
+func mustSetMtime(t *testing.T, path string, mtime time.Time) {
+	t.Helper()
+
+	err := os.Chtimes(path, time.Now(), mtime)
+	if err != nil {
+		t.Fatalf("set mtime %s failed: %v", path, err)
+	}
+}
+
+func readFileString(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s failed: %v", path, err)
+	}
+	return string(b)
+}
+
+func assertMtimeClose(t *testing.T, path string, expected time.Time, tolerance time.Duration) {
+	t.Helper()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat %s failed: %v", path, err)
+	}
+
+	delta := fi.ModTime().Sub(expected)
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta > tolerance {
+		t.Fatalf("mtime mismatch: got=%s expected=%s delta=%s", fi.ModTime(), expected, delta)
+	}
+}
+
+func TestCopyFileCopiesContentsAndMtime(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src.txt")
+	dst := filepath.Join(tmp, "dst.txt")
+
+	mustWriteFile(t, src, "hello world")
+	expectedMtime := time.Date(2021, 4, 12, 13, 14, 15, 0, time.UTC)
+	mustSetMtime(t, src, expectedMtime)
+
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile returned error: %v", err)
+	}
+
+	if got := readFileString(t, dst); got != "hello world" {
+		t.Fatalf("destination content mismatch: %q", got)
+	}
+	assertMtimeClose(t, dst, expectedMtime, time.Second)
+}
+
+func TestCopyFileOverwritesExistingDestination(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src.txt")
+	dst := filepath.Join(tmp, "dst.txt")
+
+	mustWriteFile(t, src, "new content")
+	mustWriteFile(t, dst, "old content")
+
+	expectedMtime := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	mustSetMtime(t, src, expectedMtime)
+
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile returned error: %v", err)
+	}
+
+	if got := readFileString(t, dst); got != "new content" {
+		t.Fatalf("destination content mismatch: %q", got)
+	}
+	assertMtimeClose(t, dst, expectedMtime, time.Second)
+}
+
+func TestCopyFileSourceDoesNotExist(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "missing.txt")
+	dst := filepath.Join(tmp, "dst.txt")
+
+	err := copyFile(src, dst)
+	if err == nil {
+		t.Fatal("expected error for missing source")
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got: %v", err)
+	}
+}
+
+func TestCopyFileRejectsDirectorySource(t *testing.T) {
+	tmp := t.TempDir()
+	srcDir := filepath.Join(tmp, "source-dir")
+	dst := filepath.Join(tmp, "dst.txt")
+
+	if err := os.Mkdir(srcDir, 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+
+	err := copyFile(srcDir, dst)
+	if err == nil {
+		t.Fatal("expected non-regular source error")
+	}
+	if !strings.Contains(err.Error(), "Non-regular source file") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCopyFileRejectsDirectoryDestination(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src.txt")
+	dstDir := filepath.Join(tmp, "dest-dir")
+
+	mustWriteFile(t, src, "data")
+	if err := os.Mkdir(dstDir, 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+
+	err := copyFile(src, dstDir)
+	if err == nil {
+		t.Fatal("expected non-regular destination error")
+	}
+	if !strings.Contains(err.Error(), "Non-regular destination file") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCopyFileSamePathIsNoop(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "same.txt")
+	mustWriteFile(t, src, "same file")
+
+	before := readFileString(t, src)
+	if err := copyFile(src, src); err != nil {
+		t.Fatalf("copyFile same-path returned error: %v", err)
+	}
+	after := readFileString(t, src)
+	if after != before {
+		t.Fatalf("file content unexpectedly changed: before=%q after=%q", before, after)
+	}
+}
+
+func TestCopyFileContentsCopiesEmptyFile(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "empty.txt")
+	dst := filepath.Join(tmp, "dst.txt")
+	mustWriteFile(t, src, "")
+
+	mtime := time.Date(2020, 7, 8, 9, 10, 11, 0, time.UTC)
+	if err := copyFileContents(src, dst, mtime); err != nil {
+		t.Fatalf("copyFileContents returned error: %v", err)
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if fi.Size() != 0 {
+		t.Fatalf("expected empty destination, size=%d", fi.Size())
+	}
+	assertMtimeClose(t, dst, mtime, time.Second)
+}
+
+func TestCopyFileContentsSourceDoesNotExist(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "missing.txt")
+	dst := filepath.Join(tmp, "dst.txt")
+
+	err := copyFileContents(src, dst, time.Now())
+	if err == nil {
+		t.Fatal("expected error for missing source")
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got: %v", err)
+	}
+}
+
+func TestCopyFileContentsFailsWhenDestinationParentMissing(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src.txt")
+	mustWriteFile(t, src, "data")
+
+	dst := filepath.Join(tmp, "missing-parent", "dst.txt")
+	err := copyFileContents(src, dst, time.Now())
+	if err == nil {
+		t.Fatal("expected error for missing destination parent")
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got: %v", err)
+	}
+}
+
+func TestCopyFileContentsSetsRequestedMtime(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src.txt")
+	dst := filepath.Join(tmp, "dst.txt")
+
+	mustWriteFile(t, src, "mtime target")
+	mtime := time.Date(2019, 11, 20, 21, 22, 23, 0, time.UTC)
+
+	if err := copyFileContents(src, dst, mtime); err != nil {
+		t.Fatalf("copyFileContents returned error: %v", err)
+	}
+	assertMtimeClose(t, dst, mtime, time.Second)
+}
+
+// tiffEntry is a single IFD entry for buildTIFF. value holds the raw little-endian bytes.
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func asciiEntry(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), value: append([]byte(s), 0)}
+}
+
+func rationalEntry(tag uint16, values ...[2]uint32) tiffEntry {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint32(b, v[0])
+		b = binary.LittleEndian.AppendUint32(b, v[1])
+	}
+	return tiffEntry{tag: tag, typ: 5, count: uint32(len(values)), value: b}
+}
+
+// Build a minimal little-endian TIFF blob with an IFD0 and optional Exif and GPS sub-IFDs
+func buildTIFF(ifd0, exifIFD, gpsIFD []tiffEntry) []byte {
+	blockSize := func(entries []tiffEntry) uint32 {
+		size := uint32(2 + 12*len(entries) + 4)
+		for _, e := range entries {
+			if len(e.value) > 4 {
+				size += uint32(len(e.value)+1) &^ 1
+			}
+		}
+		return size
+	}
+	ifd0 = append([]tiffEntry(nil), ifd0...)
+	if len(exifIFD) > 0 {
+		ifd0 = append(ifd0, tiffEntry{tag: 0x8769, typ: 4, count: 1, value: make([]byte, 4)})
+	}
+	if len(gpsIFD) > 0 {
+		ifd0 = append(ifd0, tiffEntry{tag: 0x8825, typ: 4, count: 1, value: make([]byte, 4)})
+	}
+	sort.Slice(ifd0, func(i, j int) bool { return ifd0[i].tag < ifd0[j].tag })
+
+	exifOffset := 8 + blockSize(ifd0)
+	gpsOffset := exifOffset + blockSize(exifIFD)
+	for i := range ifd0 {
+		switch ifd0[i].tag {
+		case 0x8769:
+			ifd0[i].value = binary.LittleEndian.AppendUint32(nil, exifOffset)
+		case 0x8825:
+			ifd0[i].value = binary.LittleEndian.AppendUint32(nil, gpsOffset)
+		}
+	}
+
+	buf := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	writeIFD := func(entries []tiffEntry) {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+		start := uint32(len(buf))
+		dataOffset := start + uint32(2+12*len(entries)+4)
+		var data []byte
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(entries)))
+		for _, e := range entries {
+			buf = binary.LittleEndian.AppendUint16(buf, e.tag)
+			buf = binary.LittleEndian.AppendUint16(buf, e.typ)
+			buf = binary.LittleEndian.AppendUint32(buf, e.count)
+			if len(e.value) <= 4 {
+				v := make([]byte, 4)
+				copy(v, e.value)
+				buf = append(buf, v...)
+				continue
+			}
+			buf = binary.LittleEndian.AppendUint32(buf, dataOffset+uint32(len(data)))
+			data = append(data, e.value...)
+			if len(data)%2 == 1 {
+				data = append(data, 0)
+			}
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, 0)
+		buf = append(buf, data...)
+	}
+	writeIFD(ifd0)
+	if len(exifIFD) > 0 {
+		writeIFD(append([]tiffEntry(nil), exifIFD...))
+	}
+	if len(gpsIFD) > 0 {
+		writeIFD(append([]tiffEntry(nil), gpsIFD...))
+	}
+	return buf
+}
+
+// Wrap a TIFF blob into a minimal JPEG with an APP1 Exif segment
+func buildJPEG(tiff []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	buf := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(payload)+2))
+	buf = append(buf, payload...)
+	return append(buf, 0xFF, 0xD9)
+}
+
+func mustWriteBytes(t *testing.T, path string, content []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("write %s failed: %v", path, err)
+	}
+}
+
+func TestCopyFileContentsVerifiesWithEachAlgorithm(t *testing.T) {
+	for algorithm := range hashAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "src.txt")
+			dst := filepath.Join(tmp, "dst.txt")
+			mustWriteFile(t, src, "verified content")
+
+			mtime := time.Date(2021, 4, 12, 13, 14, 15, 0, time.UTC)
+			if err := copyFileContentsWith(context.Background(), src, dst, mtime, copyOptions{verify: algorithm}); err != nil {
+				t.Fatalf("copyFileContentsWith returned error: %v", err)
+			}
+			if got := readFileString(t, dst); got != "verified content" {
+				t.Fatalf("destination content mismatch: %q", got)
+			}
+			assertMtimeClose(t, dst, mtime, time.Second)
+		})
+	}
+}
+
+func TestCopyFileContentsRejectsUnknownVerifyAlgorithm(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src.txt")
+	dst := filepath.Join(tmp, "dst.txt")
+	mustWriteFile(t, src, "data")
+
+	err := copyFileContentsWith(context.Background(), src, dst, time.Now(), copyOptions{verify: "rot13"})
+	if err == nil || !strings.Contains(err.Error(), "unknown hash algorithm") {
+		t.Fatalf("expected unknown algorithm error, got: %v", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("expected no destination file, stat err=%v", err)
+	}
+}
+
+func TestCopyFileContentsLeavesNoTemporaryFiles(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src.txt")
+	dstDir := filepath.Join(tmp, "out")
+	if err := os.Mkdir(dstDir, 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	mustWriteFile(t, src, "atomic")
+
+	if err := copyFileContents(src, filepath.Join(dstDir, "dst.txt"), time.Now()); err != nil {
+		t.Fatalf("copyFileContents returned error: %v", err)
+	}
+	// Reading a directory fails mid-copy, which must not leave the temporary file behind
+	if err := copyFileContents(tmp, filepath.Join(dstDir, "broken.txt"), time.Now()); err == nil {
+		t.Fatal("expected error when copying from a directory")
+	}
+
+	entries, err := os.ReadDir(dstDir)
+	if err != nil {
+		t.Fatalf("read dir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "dst.txt" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("expected only dst.txt, got: %v", names)
+	}
+}
+
+func TestCleanupTempFilesRemovesLeftovers(t *testing.T) {
+	root := t.TempDir()
+	folder := filepath.Join(root, "2024-07-08-jpg")
+	untouched := filepath.Join(root, "2024-07-09-jpg")
+	for _, dir := range []string{folder, untouched} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+	}
+	leftover := filepath.Join(folder, ".IMG_0001.JPG.123456"+tempFileSuffix)
+	kept := filepath.Join(folder, "IMG_0002.JPG")
+	elsewhere := filepath.Join(untouched, ".IMG_0003.JPG.123456"+tempFileSuffix)
+	mustWriteFile(t, leftover, "trunc")
+	mustWriteFile(t, kept, "complete")
+	mustWriteFile(t, elsewhere, "trunc")
+
+	// A run that writes to folder cleans it and leaves the folders it does not write to alone
+	from := filepath.Join(root, "from")
+	if err := os.Mkdir(from, 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	mustWriteFile(t, filepath.Join(from, "IMG_0004.JPG"), "new")
+	mustSetMtime(t, filepath.Join(from, "IMG_0004.JPG"), time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC))
+	cfg := Options{From: from, To: root, End: time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC), MaxWorkers: 1, UseModTime: true}
+	if _, err := runImport(context.Background(), cfg, io.Discard, nil); err != nil {
+		t.Fatalf("runImport returned error: %v", err)
+	}
+
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Fatalf("expected leftover temporary file to be removed, stat err=%v", err)
+	}
+	if got := readFileString(t, kept); got != "complete" {
+		t.Fatalf("expected regular file to be kept, got: %q", got)
+	}
+	if _, err := os.Stat(elsewhere); err != nil {
+		t.Fatalf("expected the folder the run did not write to to be left alone, stat err=%v", err)
+	}
+}
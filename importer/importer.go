@@ -0,0 +1,102 @@
+// Package importer copies or moves photos and videos into a library sorted by capture date.
+//
+// An Importer runs a single import described by Options. The library maintenance functions
+// Verify, Undo, Reorganize, Stats and Inspect work on the state an import leaves below the
+// destination root.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultWorkers is the number of files processed concurrently when Options.MaxWorkers is 0
+const DefaultWorkers = 10
+
+// Importer runs an import
+type Importer struct {
+	opts Options
+}
+
+// Create an Importer after validating opts and filling in defaults
+func New(opts Options) (*Importer, error) {
+	if opts.From == "" || opts.To == "" {
+		return nil, fmt.Errorf("need source and target directory")
+	}
+	if opts.MaxWorkers == 0 {
+		opts.MaxWorkers = DefaultWorkers
+	}
+	if opts.MaxWorkers < 0 {
+		return nil, fmt.Errorf("MaxWorkers must be >= 0")
+	}
+	if opts.MaxDepth < 0 {
+		return nil, fmt.Errorf("MaxDepth must be >= 0")
+	}
+	if opts.End.IsZero() {
+		opts.End = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	}
+	if opts.Layout == "" {
+		opts.Layout = DefaultLayout
+	}
+	if err := ValidateLayout(opts.Layout); err != nil {
+		return nil, err
+	}
+	if err := ValidateRename(opts.Rename); err != nil {
+		return nil, err
+	}
+	if opts.VerifyHash == "" {
+		opts.VerifyHash = DefaultHashAlgorithm
+	}
+	if err := ValidateHashAlgorithm(opts.VerifyHash); err != nil {
+		return nil, err
+	}
+	opts.VerifyHash = strings.ToLower(opts.VerifyHash)
+	policy, err := ParseConflictPolicy(opts.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+	opts.OnConflict = policy
+	if opts.Mtime, err = ParseMtime(opts.Mtime); err != nil {
+		return nil, err
+	}
+	opts.Filter = strings.ToLower(opts.Filter)
+	if len(opts.Resolvers) == 0 {
+		opts.Resolvers = DefaultResolvers()
+	}
+	return &Importer{opts: opts}, nil
+}
+
+// Run the import. The returned error reports files that failed as well as problems that
+// stopped the run, the Summary is filled in either way.
+func (im *Importer) Run(ctx context.Context) (Summary, error) {
+	out := im.opts.Output
+	if out == nil {
+		out = io.Discard
+	}
+	return runImport(ctx, im.opts, out, im.opts.Progress)
+}
+
+// ValidateLayout reports whether raw is a valid folder template
+func ValidateLayout(raw string) error {
+	_, err := parseLayout(raw)
+	return err
+}
+
+// ValidateRename reports whether raw is a valid file name template. The empty string keeps
+// the original names and is valid.
+func ValidateRename(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	_, err := parseRename(raw)
+	return err
+}
+
+// ValidateHashAlgorithm reports whether algorithm names a supported checksum
+func ValidateHashAlgorithm(algorithm string) error {
+	_, err := newHash(algorithm)
+	return err
+}
@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewValidatesOptionsAndFillsDefaults(t *testing.T) {
+	if _, err := New(Options{From: "/src"}); err == nil {
+		t.Fatal("expected error without a destination")
+	}
+	if _, err := New(Options{From: "/src", To: "/dst", Layout: "{nope}"}); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("expected layout error, got: %v", err)
+	}
+
+	im, err := New(Options{From: "/src", To: "/dst", Filter: "JPG", VerifyHash: "SHA1"})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	opts := im.opts
+	if opts.MaxWorkers != DefaultWorkers || opts.Layout != DefaultLayout || opts.OnConflict != DefaultConflictPolicy {
+		t.Fatalf("expected defaults to be filled in, got: %+v", opts)
+	}
+	if opts.Filter != "jpg" || opts.VerifyHash != "sha1" || opts.End.Year() != 9999 {
+		t.Fatalf("expected normalized options, got: %+v", opts)
+	}
+}
+
+func TestImporterRunReturnsFileResults(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	path := filepath.Join(from, "a.jpg")
+	mustWriteFile(t, path, "a")
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	mustSetMtime(t, path, mtime)
+
+	var out bytes.Buffer
+	im, err := New(Options{From: from, To: to, UseModTime: true, Output: &out})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	summary, err := im.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Copied != 1 || len(summary.Files) != 1 {
+		t.Fatalf("expected one copied file, got: %+v", summary)
+	}
+	f := summary.Files[0]
+	if f.Outcome != OutcomeCopied || f.Destination != filepath.Join(to, "2024-05-06-jpg", "a.jpg") || f.SHA256 == "" {
+		t.Fatalf("unexpected file result: %+v", f)
+	}
+	if !f.Meta.Timestamp.Equal(mtime) || f.Meta.Source != TimeSourceModTime {
+		t.Fatalf("expected the modtime as timestamp, got: %+v", f.Meta)
+	}
+}
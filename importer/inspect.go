@@ -0,0 +1,170 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// InspectOptions select the files Inspect looks at and the layout used to show their destination
+type InspectOptions struct {
+	Paths  []string
+	To     string
+	Layout string
+	Rename string
+	// TimeOptions date the files, the choice of their resolver chain is shown
+	TimeOptions
+}
+
+// Print every timestamp candidate of the given files (the files directly inside folders),
+// which one resolveTimestamp picks and why, and the destination path it leads to
+func Inspect(cfg InspectOptions, out io.Writer) error {
+	root := cfg.To
+	if root == "" {
+		root = "<to>"
+	}
+	planner, err := newDestinationPlanner(Options{To: root, Layout: cfg.Layout, Rename: cfg.Rename, TimeOptions: TimeOptions{FolderTimeZone: cfg.FolderTimeZone}})
+	if err != nil {
+		return err
+	}
+	planner.seqFromDisk = cfg.To != ""
+
+	tc := cfg.timestampConfig()
+	if len(tc.resolvers) == 0 {
+		tc.resolvers = DefaultResolvers()
+	}
+
+	failed := 0
+	for _, path := range cfg.Paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		if !fi.IsDir() {
+			inspectFile(out, planner, tc, path, importJob{rel: fi.Name(), info: fi})
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				fmt.Fprintf(out, "%s: %v\n", filepath.Join(path, e.Name()), err)
+				failed++
+				continue
+			}
+			inspectFile(out, planner, tc, filepath.Join(path, e.Name()), importJob{rel: e.Name(), info: info})
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d paths could not be inspected", failed)
+	}
+	return nil
+}
+
+func inspectFile(out io.Writer, planner *destinationPlanner, tc timestampConfig, path string, job importJob) {
+	fmt.Fprintf(out, "%s\n", path)
+	ctx := context.Background()
+	f := &SourceFile{Path: path, Info: job.info, Location: tc.location}
+	tags, err := f.exifTags(ctx)
+	if err != nil {
+		fmt.Fprintf(out, "  error: %v\n\n", err)
+		return
+	}
+	rows := [][2]string{
+		{"DateTimeOriginal", orNone(tags.dateTimeOriginal)},
+		{"OffsetTimeOriginal", orNone(tags.offsetTimeOriginal)},
+		{"OffsetTime", orNone(tags.offsetTime)},
+		{"SubSecTimeOriginal", orNone(tags.subSecTimeOriginal)},
+		{"GPS position", inspectGPS(tags)},
+	}
+
+	// The candidate of every resolver, including the built-in ones the chain leaves out
+	inChain := make(map[string]bool)
+	for _, r := range tc.resolvers {
+		inChain[r.Name()] = true
+	}
+	candidates := append([]TimestampResolver(nil), tc.resolvers...)
+	for _, r := range builtinResolvers {
+		if !inChain[r.Name()] {
+			candidates = append(candidates, r)
+		}
+	}
+	for _, r := range candidates {
+		res, err := r.Resolve(ctx, f)
+		var value string
+		switch {
+		case err != nil:
+			value = "error: " + err.Error()
+		case res.Timestamp.IsZero():
+			value = "(none)"
+		default:
+			value = fmt.Sprintf("%s (confidence %.2f, %s)", res.Timestamp.Format(time.RFC3339Nano), res.Confidence, res.Reason)
+		}
+		if !inChain[r.Name()] {
+			value += " [not in --time-source]"
+		}
+		rows = append(rows, [2]string{r.Name(), value})
+	}
+
+	var notes []string
+	meta, _ := resolveTimestamp(ctx, tc, path, job.info, func(format string, args ...any) {
+		notes = append(notes, fmt.Sprintf(format, args...))
+	})
+	rows = append(rows,
+		[2]string{"camera", orNone(cameraName(meta.Make, meta.Model))},
+		[2]string{"chosen", fmt.Sprintf("%s (%s)", meta.Timestamp.Format(time.RFC3339Nano), meta.Source)},
+		[2]string{"confidence", fmt.Sprintf("%.2f", meta.Confidence)},
+		[2]string{"because", meta.Reason},
+	)
+
+	for _, note := range notes {
+		// The fallback reason is already shown above
+		if !strings.HasSuffix(note, meta.Reason) {
+			rows = append(rows, [2]string{"note", note})
+		}
+	}
+	folder, name, _ := planner.destination(job, meta)
+	rows = append(rows, [2]string{"destination", filepath.Join(folder, name)})
+	for _, row := range rows {
+		fmt.Fprintf(out, "  %-21s %s\n", row[0]+":", row[1])
+	}
+	fmt.Fprintln(out)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// Describe the GPS position of the EXIF tags and the time zone found for it
+func inspectGPS(tags *exifTags) string {
+	if tags.gpsLatitude == "" && tags.gpsLongitude == "" {
+		return "(none)"
+	}
+	lat, latOK := parseGPSCoordinate(tags.gpsLatitude, tags.gpsLatitudeRef, 90)
+	lon, lonOK := parseGPSCoordinate(tags.gpsLongitude, tags.gpsLongitudeRef, 180)
+	if !latOK || !lonOK {
+		return fmt.Sprintf("unreadable (%s %s, %s %s)", tags.gpsLatitude, tags.gpsLatitudeRef, tags.gpsLongitude, tags.gpsLongitudeRef)
+	}
+	zone := "no known time zone"
+	if loc := tags.gpsZone(); loc != nil {
+		zone = loc.String()
+	}
+	return fmt.Sprintf("%.5f, %.5f (%s)", lat, lon, zone)
+}
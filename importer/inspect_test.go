@@ -0,0 +1,45 @@
+package importer
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunInspectShowsCandidatesAndChoice(t *testing.T) {
+	dir := t.TempDir()
+	tiff := buildTIFF(
+		[]tiffEntry{asciiEntry(0x010f, "Canon"), asciiEntry(0x0110, "Canon EOS R6")},
+		[]tiffEntry{asciiEntry(0x9003, "2024:06:01 14:22:33"), asciiEntry(0x9011, "+02:00")},
+		nil,
+	)
+	mustWriteBytes(t, filepath.Join(dir, "IMG_0001.JPG"), buildJPEG(tiff))
+	plain := filepath.Join(dir, "IMG_20230102_030405.png")
+	mustWriteFile(t, plain, "no metadata")
+	mustSetMtime(t, plain, time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local))
+
+	cfg := InspectOptions{Paths: []string{dir}, To: "/library", Layout: "{yyyy}/{date}"}
+	var out bytes.Buffer
+	if err := Inspect(cfg, &out); err != nil {
+		t.Fatalf("Inspect returned error: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"DateTimeOriginal:     2024:06:01 14:22:33",
+		"OffsetTimeOriginal:   +02:00",
+		"chosen:               2024-06-01T14:22:33+02:00 (exif)",
+		"confidence:           1.00",
+		"because:              EXIF DateTimeOriginal with OffsetTimeOriginal",
+		"xmp:                  (none) [not in --time-source]",
+		"destination:          " + filepath.Join("/library", "2024", "2024-06-01", "IMG_0001.JPG"),
+		"filename:             2023-01-02T03:04:05",
+		"because:              date and time in the file name",
+		"destination:          " + filepath.Join("/library", "2023", "2023-01-02", "IMG_20230102_030405.png"),
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output:\n%s", want, got)
+		}
+	}
+}
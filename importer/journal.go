@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const journalFileName = "journal.jsonl"
+
+// journalEntry is one line of the import journal, appended whenever a file finishes
+type journalEntry struct {
+	Source      string    `json:"source"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	Outcome     string    `json:"outcome"`
+	Destination string    `json:"destination,omitempty"`
+	Finished    time.Time `json:"finished"`
+}
+
+// Outcomes that mean a file needs no further work on --resume. Out-of-range files are not
+// final because the next run may use a different date window.
+var journalFinalOutcomes = map[string]bool{
+	OutcomeCopied:    true,
+	OutcomeMoved:     true,
+	OutcomeSkipped:   true,
+	OutcomeDuplicate: true,
+}
+
+// importJournal is the append-only record of finished files below the destination root. It
+// is shared by all workers of a run.
+type importJournal struct {
+	mu       sync.Mutex
+	file     *os.File
+	finished map[string]journalEntry // latest final entry per absolute source path
+}
+
+// Open the journal for appending. With resume set the existing entries are loaded so
+// finished files can be skipped. A read-only journal (for dry runs) records nothing.
+func openJournal(root string, resume, readOnly bool) (*importJournal, error) {
+	dir := filepath.Join(root, metaDirName)
+	path := filepath.Join(dir, journalFileName)
+	j := &importJournal{finished: make(map[string]journalEntry)}
+	if resume {
+		if err := j.load(path); err != nil {
+			return nil, err
+		}
+	}
+	if readOnly {
+		return j, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	j.file = f
+	return j, nil
+}
+
+func (j *importJournal) load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry journalEntry
+		// A torn last line from an interrupted run is simply ignored
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if journalFinalOutcomes[entry.Outcome] {
+			j.finished[entry.Source] = entry
+		} else {
+			delete(j.finished, entry.Source)
+		}
+	}
+	return scanner.Err()
+}
+
+// Report whether the source file was already finished by an earlier run and is unchanged
+func (j *importJournal) done(source string, info os.FileInfo) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.finished[source]
+	return ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime())
+}
+
+// Append a finished file to the journal, keyed by its absolute source path
+func (j *importJournal) record(result FileResult, info os.FileInfo) error {
+	return j.append(journalEntry{
+		Source:      result.Source,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Outcome:     result.Outcome,
+		Destination: result.Destination,
+		Finished:    time.Now(),
+	})
+}
+
+// Append an entry to the journal
+func (j *importJournal) append(entry journalEntry) error {
+	if j == nil || j.file == nil {
+		return nil
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(b, '\n'))
+	return err
+}
+
+func (j *importJournal) Close() error {
+	if j == nil || j.file == nil {
+		return nil
+	}
+	return j.file.Close()
+}
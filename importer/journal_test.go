@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunImportResumeSkipsFinishedFiles(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	folder := filepath.Join(to, "2024-07-08-jpg")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		t.Fatalf("mkdir folder failed: %v", err)
+	}
+
+	mtime := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+	for _, name := range []string{"done.jpg", "failed.jpg"} {
+		path := filepath.Join(from, name)
+		mustWriteFile(t, path, name)
+		mustSetMtime(t, path, mtime)
+	}
+	mustWriteFile(t, filepath.Join(folder, "failed.jpg"), "blocking file")
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+		UseModTime: true,
+		OnConflict: conflictFail,
+	}
+
+	var out bytes.Buffer
+	summary, err := runImport(context.Background(), cfg, &out, nil)
+	if err == nil || summary.Copied != 1 || summary.Failed != 1 {
+		t.Fatalf("expected one copy and one failure, got: %+v (err=%v)", summary, err)
+	}
+
+	// Remove the copied file: a resumed run must trust the journal and not copy it again
+	if err := os.Remove(filepath.Join(folder, "done.jpg")); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	cfg.Resume = true
+	cfg.OnConflict = conflictRename
+	out.Reset()
+	summary, err = runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Resumed != 1 || summary.Copied != 1 {
+		t.Fatalf("expected one resumed and one retried file, got: %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "done.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("expected finished file to be skipped, stat err=%v", err)
+	}
+	if got := readFileString(t, filepath.Join(folder, "failed-1.jpg")); got != "failed.jpg" {
+		t.Fatalf("expected failed file to be retried, got: %q", got)
+	}
+
+	// A changed source no longer matches its journal entry
+	mustSetMtime(t, filepath.Join(from, "done.jpg"), mtime.Add(time.Hour))
+	out.Reset()
+	summary, err = runImport(context.Background(), cfg, &out, nil)
+	if err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Resumed != 1 || summary.Copied != 1 {
+		t.Fatalf("expected changed file to be imported again, got: %+v", summary)
+	}
+}
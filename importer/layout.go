@@ -0,0 +1,470 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLayout reproduces the historical YYYY-MM-DD-<ext> folder naming
+const DefaultLayout = "{date}-{ext}"
+
+// Tokens accepted by --layout. Each renders to a single path component except {subdir},
+// which reproduces the source subfolder and may span several.
+var layoutTokens = map[string]bool{
+	"yyyy": true, "yy": true, "mm": true, "dd": true,
+	"HH": true, "min": true, "ss": true,
+	"date": true, "yyyyMMdd": true, "HHmmss": true,
+	"ext": true, "make": true, "model": true, "camera": true, "lens": true,
+	"subdir": true,
+}
+
+// Tokens accepted by --rename: the date and camera tokens, the fraction of the second in
+// milliseconds ({SSS}) or nanoseconds ({ns}), the original base name and a per-folder
+// sequence counter ({seq} or zero-padded {seq:4}).
+var renameTokens = map[string]bool{
+	"yyyy": true, "yy": true, "mm": true, "dd": true,
+	"HH": true, "min": true, "ss": true, "SSS": true, "ns": true,
+	"date": true, "yyyyMMdd": true, "HHmmss": true,
+	"ext": true, "make": true, "model": true, "camera": true, "lens": true,
+	"orig": true, "seq": true,
+}
+
+// templateSegment is either literal text or a {token} placeholder. width is the zero-padding
+// requested with {seq:N}.
+type templateSegment struct {
+	literal string
+	token   string
+	width   int
+}
+
+// pathTemplate is a parsed --layout or --rename template
+type pathTemplate struct {
+	raw      string
+	segments []templateSegment
+}
+
+// templateVars holds everything a template can refer to for a single file
+type templateVars struct {
+	meta   FileMeta
+	ext    string
+	subdir string
+	orig   string
+	seq    int
+}
+
+// Parse a template, rejecting unknown tokens and unbalanced braces
+func parseTemplate(raw string, allowed map[string]bool) (pathTemplate, error) {
+	t := pathTemplate{raw: raw}
+	rest := raw
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.segments = append(t.segments, templateSegment{literal: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return pathTemplate{}, fmt.Errorf("unexpected '}' in template %q", raw)
+		}
+		if open > 0 {
+			t.segments = append(t.segments, templateSegment{literal: rest[:open]})
+		}
+		closing := strings.IndexByte(rest[open:], '}')
+		if closing < 0 {
+			return pathTemplate{}, fmt.Errorf("unterminated '{' in template %q", raw)
+		}
+		token, arg, hasArg := strings.Cut(rest[open+1:open+closing], ":")
+		if !allowed[token] {
+			return pathTemplate{}, fmt.Errorf("unknown token {%s} in template %q", token, raw)
+		}
+		seg := templateSegment{token: token}
+		if hasArg {
+			width, err := strconv.Atoi(arg)
+			if token != "seq" || err != nil || width < 1 || width > 9 {
+				return pathTemplate{}, fmt.Errorf("invalid argument in {%s:%s} in template %q", token, arg, raw)
+			}
+			seg.width = width
+		}
+		t.segments = append(t.segments, seg)
+		rest = rest[open+closing+1:]
+	}
+	return t, nil
+}
+
+// Parse a --layout template. The rendered result must stay below the destination root.
+func parseLayout(raw string) (pathTemplate, error) {
+	if raw == "" {
+		raw = DefaultLayout
+	}
+	if strings.HasPrefix(raw, "/") || filepath.IsAbs(raw) {
+		return pathTemplate{}, fmt.Errorf("layout %q must be relative to the destination", raw)
+	}
+	for _, part := range strings.Split(raw, "/") {
+		if part == "" || part == "." || part == ".." {
+			return pathTemplate{}, fmt.Errorf("layout %q contains an empty or relative path element", raw)
+		}
+	}
+	return parseTemplate(raw, layoutTokens)
+}
+
+// Parse a --rename template. It produces a single file name, the original extension is kept.
+func parseRename(raw string) (pathTemplate, error) {
+	if strings.ContainsAny(raw, `/\`) {
+		return pathTemplate{}, fmt.Errorf("rename template %q must not contain path separators", raw)
+	}
+	return parseTemplate(raw, renameTokens)
+}
+
+// Render the template for a file. Forward slashes in the template separate directories.
+func (t pathTemplate) render(vars templateVars) string {
+	var sb strings.Builder
+	for _, seg := range t.segments {
+		if seg.token == "" {
+			sb.WriteString(seg.literal)
+			continue
+		}
+		if seg.token == "seq" {
+			sb.WriteString(fmt.Sprintf("%0*d", seg.width, vars.seq))
+			continue
+		}
+		sb.WriteString(vars.value(seg.token))
+	}
+	return filepath.FromSlash(sb.String())
+}
+
+// Report whether the template refers to the given token
+func (t pathTemplate) uses(token string) bool {
+	for _, seg := range t.segments {
+		if seg.token == token {
+			return true
+		}
+	}
+	return false
+}
+
+func (v templateVars) value(token string) string {
+	ts := v.meta.Timestamp
+	switch token {
+	case "yyyy":
+		return ts.Format("2006")
+	case "yy":
+		return ts.Format("06")
+	case "mm":
+		return ts.Format("01")
+	case "dd":
+		return ts.Format("02")
+	case "HH":
+		return ts.Format("15")
+	case "min":
+		return ts.Format("04")
+	case "ss":
+		return ts.Format("05")
+	case "SSS":
+		return fmt.Sprintf("%03d", ts.Nanosecond()/int(time.Millisecond))
+	case "ns":
+		return fmt.Sprintf("%09d", ts.Nanosecond())
+	case "date":
+		return ts.Format("2006-01-02")
+	case "yyyyMMdd":
+		return ts.Format("20060102")
+	case "HHmmss":
+		return ts.Format("150405")
+	case "ext":
+		return v.ext
+	case "make":
+		return sanitizeComponent(v.meta.Make)
+	case "model":
+		return sanitizeComponent(v.meta.Model)
+	case "camera":
+		return sanitizeComponent(cameraName(v.meta.Make, v.meta.Model))
+	case "lens":
+		return sanitizeComponent(v.meta.Lens)
+	case "subdir":
+		return filepath.ToSlash(v.subdir)
+	case "orig":
+		return v.orig
+	}
+	return ""
+}
+
+// Combine make and model into a single camera name, avoiding "Canon Canon EOS R5"
+func cameraName(cameraMake, cameraModel string) string {
+	cameraMake = strings.TrimSpace(cameraMake)
+	cameraModel = strings.TrimSpace(cameraModel)
+	switch {
+	case cameraModel == "":
+		return cameraMake
+	case cameraMake == "" || strings.HasPrefix(strings.ToLower(cameraModel), strings.ToLower(cameraMake)):
+		return cameraModel
+	}
+	return cameraMake + " " + cameraModel
+}
+
+// Make a metadata value safe to use as a single path component
+func sanitizeComponent(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.Trim(s, " .")
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// destinationPlanner turns a resolved file into its destination path. It is shared by all
+// workers of a run, so the per-folder sequence counters are guarded by a mutex.
+type destinationPlanner struct {
+	root   string
+	layout pathTemplate
+	rename *pathTemplate
+	// backupDir receives files replaced by the overwrite policy, empty to not keep them
+	backupDir string
+	// location is the zone folder and file names are rendered in, nil keeps the zone each
+	// timestamp was resolved in
+	location *time.Location
+	// seqFromDisk continues the {seq} counter of a folder after the highest number its files
+	// already carry
+	seqFromDisk bool
+
+	mu       sync.Mutex
+	seq      map[string]*folderSeq
+	claimed  map[string]bool
+	cleanups map[string]*sync.Once
+}
+
+// folderSeq hands out the {seq} numbers of a folder. Numbers given back by files that were
+// not placed are handed out again first, so skipped files leave no gaps.
+type folderSeq struct {
+	last     int
+	released []int
+}
+
+func newDestinationPlanner(cfg Options) (*destinationPlanner, error) {
+	layout, err := parseLayout(cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+	p := &destinationPlanner{root: cfg.To, layout: layout, location: cfg.FolderTimeZone, seq: make(map[string]*folderSeq), claimed: make(map[string]bool), cleanups: make(map[string]*sync.Once)}
+	if cfg.Rename != "" {
+		rename, err := parseRename(cfg.Rename)
+		if err != nil {
+			return nil, err
+		}
+		p.rename = &rename
+	}
+	return p, nil
+}
+
+// Compute the destination folder and file name for a file, and the {seq} number the name
+// took (0 without one). Callers that do not place the file give the number back with
+// releaseSeq.
+func (p *destinationPlanner) destination(job importJob, meta FileMeta) (string, string, int) {
+	if p.location != nil {
+		meta.Timestamp = meta.Timestamp.In(p.location)
+	}
+	folder := layoutFolder(p.root, p.layout, job, meta)
+	name := job.info.Name()
+	if p.rename == nil {
+		return folder, name, 0
+	}
+
+	ext := filepath.Ext(name)
+	vars := templateVars{
+		meta: meta,
+		ext:  strings.TrimPrefix(strings.ToLower(ext), "."),
+		orig: strings.TrimSuffix(name, ext),
+	}
+	if p.rename.uses("seq") {
+		vars.seq = p.nextSeq(folder)
+	}
+	return folder, p.rename.render(vars) + ext, vars.seq
+}
+
+// Take the next {seq} number of a folder
+func (p *destinationPlanner) nextSeq(folder string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.seq[folder]
+	if s == nil {
+		s = &folderSeq{}
+		if p.seqFromDisk {
+			s.last = highestSeq(folder, *p.rename)
+		}
+		p.seq[folder] = s
+	}
+	if len(s.released) > 0 {
+		n := s.released[0]
+		s.released = s.released[1:]
+		return n
+	}
+	s.last++
+	return s.last
+}
+
+// Give back the {seq} number of a file that was not placed, together with the claim on the
+// path rendered for it (empty when the file did not claim it), so the next file taking the
+// number can use that path
+func (p *destinationPlanner) releaseSeq(folder string, n int, path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if path != "" {
+		delete(p.claimed, path)
+	}
+	s := p.seq[folder]
+	if s == nil || n <= 0 {
+		return
+	}
+	s.released = append(s.released, n)
+	sort.Ints(s.released)
+	// Numbers at the end of the counter are simply taken back
+	for len(s.released) > 0 && s.released[len(s.released)-1] == s.last {
+		s.released = s.released[:len(s.released)-1]
+		s.last--
+	}
+}
+
+// Find the highest {seq} number among the files in folder whose names the rename template
+// could have produced, 0 when there are none
+func highestSeq(folder string, rename pathTemplate) int {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return 0
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	captured := false
+	for _, seg := range rename.segments {
+		switch {
+		case seg.token == "":
+			expr.WriteString(regexp.QuoteMeta(seg.literal))
+		case seg.token == "seq" && !captured:
+			expr.WriteString("(" + tokenPattern(seg) + ")")
+			captured = true
+		default:
+			expr.WriteString(tokenPattern(seg))
+		}
+	}
+	// The original extension follows the rendered name
+	expr.WriteString(`(?:\.[^.]*)?$`)
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return 0
+	}
+	highest := 0
+	for _, e := range entries {
+		m := re.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(m[1]); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// Regular expression for what a rename token renders to. Date and sequence tokens have a
+// fixed width so adjacent tokens split the same way they were rendered.
+func tokenPattern(seg templateSegment) string {
+	switch seg.token {
+	case "yyyy":
+		return `\d{4}`
+	case "yy", "mm", "dd", "HH", "min", "ss":
+		return `\d{2}`
+	case "SSS":
+		return `\d{3}`
+	case "ns":
+		return `\d{9}`
+	case "date":
+		return `\d{4}-\d{2}-\d{2}`
+	case "yyyyMMdd":
+		return `\d{8}`
+	case "HHmmss":
+		return `\d{6}`
+	case "seq":
+		if seg.width > 0 {
+			return fmt.Sprintf(`\d{%d}`, seg.width)
+		}
+		return `[1-9]\d*`
+	case "ext":
+		return `[^.]*`
+	case "make", "model", "camera", "lens":
+		// sanitizeComponent never returns an empty string
+		return `.+`
+	}
+	return `.*`
+}
+
+// Claim a destination path for this run. It reports false when another file of the same run
+// already claimed the path.
+func (p *destinationPlanner) claim(path string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.claimed[path] {
+		return false
+	}
+	p.claimed[path] = true
+	return true
+}
+
+// Claim the first alternative name (name-1, name-2, ...) in folder that neither exists on
+// disk nor was claimed by another file of this run
+func (p *destinationPlanner) claimAlternative(folder, name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for n := 1; ; n++ {
+		path := filepath.Join(folder, alternativeName(name, n))
+		if p.claimed[path] {
+			continue
+		}
+		if _, err := os.Lstat(path); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		p.claimed[path] = true
+		return path, nil
+	}
+}
+
+// Remove the temporary files an interrupted run left in folder. Only the first call for a
+// folder cleans it, and later callers wait until it is done, so files this run is writing
+// there are never removed.
+func (p *destinationPlanner) cleanupFolder(folder string, logf func(string, ...any)) {
+	p.mu.Lock()
+	once := p.cleanups[folder]
+	if once == nil {
+		once = new(sync.Once)
+		p.cleanups[folder] = once
+	}
+	p.mu.Unlock()
+	once.Do(func() { cleanupTempFiles(folder, logf) })
+}
+
+// Compute the destination folder for a file from the layout template
+func layoutFolder(root string, layout pathTemplate, job importJob, meta FileMeta) string {
+	subdir := filepath.Dir(job.rel)
+	if subdir == "." {
+		subdir = ""
+	}
+	vars := templateVars{
+		meta:   meta,
+		ext:    strings.TrimPrefix(strings.ToLower(filepath.Ext(job.info.Name())), "."),
+		subdir: subdir,
+	}
+	return filepath.Join(root, layout.render(vars))
+}
@@ -0,0 +1,201 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLayoutRejectsInvalidTemplates(t *testing.T) {
+	cases := map[string]string{
+		"{yyyy}/{month}": "unknown token",
+		"{yyyy/{mm}":     "unknown token",
+		"{yyyy}}":        "unexpected '}'",
+		"{date":          "unterminated",
+		"/{date}":        "relative to the destination",
+		"{yyyy}/../x":    "relative path element",
+		"{yyyy}//{mm}":   "empty or relative path element",
+	}
+	for layout, want := range cases {
+		_, err := parseLayout(layout)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("layout %q: expected error containing %q, got: %v", layout, want, err)
+		}
+	}
+}
+
+func TestLayoutFolderRendersTokens(t *testing.T) {
+	layout, err := parseLayout("{yyyy}/{mm}/{yyyy}-{mm}-{dd}/{ext}")
+	if err != nil {
+		t.Fatalf("parseLayout returned error: %v", err)
+	}
+	fi := fakeFileInfo(t, "IMG_0001.CR3")
+	meta := FileMeta{Timestamp: time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)}
+
+	got := layoutFolder("/lib", layout, importJob{rel: "DCIM/100CANON/IMG_0001.CR3", info: fi}, meta)
+	want := filepath.Join("/lib", "2024", "06", "2024-06-03", "cr3")
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestLayoutFolderSanitizesCameraAndKeepsSubdir(t *testing.T) {
+	layout, err := parseLayout("{camera}/{lens}/{subdir}")
+	if err != nil {
+		t.Fatalf("parseLayout returned error: %v", err)
+	}
+	fi := fakeFileInfo(t, "IMG_0001.JPG")
+	meta := FileMeta{
+		Timestamp: time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC),
+		Make:      "Canon",
+		Model:     "Canon EOS R5",
+		Lens:      "RF24-70mm F2.8/L",
+	}
+
+	got := layoutFolder("/lib", layout, importJob{rel: "DCIM/100CANON/IMG_0001.JPG", info: fi}, meta)
+	want := filepath.Join("/lib", "Canon EOS R5", "RF24-70mm F2.8_L", "DCIM", "100CANON")
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	meta.Make, meta.Model, meta.Lens = "", "", ""
+	got = layoutFolder("/lib", layout, importJob{rel: "IMG_0001.JPG", info: fi}, meta)
+	want = filepath.Join("/lib", "unknown", "unknown")
+	if got != want {
+		t.Fatalf("expected %q for missing metadata, got %q", want, got)
+	}
+}
+
+func fakeFileInfo(t *testing.T, name string) os.FileInfo {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	mustWriteFile(t, path, "")
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat %s failed: %v", path, err)
+	}
+	return fi
+}
+
+func TestParseRenameValidatesSequenceWidth(t *testing.T) {
+	if _, err := parseRename("{yyyyMMdd}_{HHmmss}_{seq:4}_{orig}"); err != nil {
+		t.Fatalf("expected valid rename template, got: %v", err)
+	}
+	for _, raw := range []string{"{seq:0}", "{seq:x}", "{date:4}", "{subdir}_{orig}", "{yyyy}/{orig}"} {
+		if _, err := parseRename(raw); err == nil {
+			t.Fatalf("expected error for rename template %q", raw)
+		}
+	}
+}
+
+func TestDestinationPlannerRenamesWithPerFolderSequence(t *testing.T) {
+	planner, err := newDestinationPlanner(Options{To: "/lib", Rename: "{yyyyMMdd}_{HHmmss}_{seq:4}_{orig}"})
+	if err != nil {
+		t.Fatalf("newDestinationPlanner returned error: %v", err)
+	}
+	day1 := FileMeta{Timestamp: time.Date(2024, 6, 3, 14, 22, 33, 0, time.UTC)}
+	day2 := FileMeta{Timestamp: time.Date(2024, 6, 4, 8, 0, 0, 0, time.UTC)}
+	fi := fakeFileInfo(t, "IMG_0001.CR3")
+	job := importJob{rel: "IMG_0001.CR3", info: fi}
+
+	var names []string
+	for _, meta := range []FileMeta{day1, day1, day2} {
+		_, name, _ := planner.destination(job, meta)
+		names = append(names, name)
+	}
+	want := []string{
+		"20240603_142233_0001_IMG_0001.CR3",
+		"20240603_142233_0002_IMG_0001.CR3",
+		"20240604_080000_0001_IMG_0001.CR3",
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("name %d: expected %q, got %q", i, want[i], names[i])
+		}
+	}
+}
+
+func TestDestinationPlannerSequenceSkipsNoNumbers(t *testing.T) {
+	folder := filepath.Join(t.TempDir(), "2024-06-03-cr3")
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	// Files of an earlier import, one renamed away from a conflict, and an unrelated file
+	for _, name := range []string{"20240603_0007_IMG_0001.CR3", "20240603_0003_IMG_0002-1.CR3", "20240603_9999.txt"} {
+		mustWriteFile(t, filepath.Join(folder, name), name)
+	}
+	planner, err := newDestinationPlanner(Options{To: filepath.Dir(folder), Rename: "{yyyyMMdd}_{seq:4}_{orig}"})
+	if err != nil {
+		t.Fatalf("newDestinationPlanner returned error: %v", err)
+	}
+	planner.seqFromDisk = true
+	meta := FileMeta{Timestamp: time.Date(2024, 6, 3, 14, 22, 33, 0, time.UTC)}
+	job := importJob{rel: "IMG_0042.CR3", info: fakeFileInfo(t, "IMG_0042.CR3")}
+
+	next := func() (string, int) {
+		_, name, seq := planner.destination(job, meta)
+		return name, seq
+	}
+	if name, seq := next(); name != "20240603_0008_IMG_0042.CR3" || seq != 8 {
+		t.Fatalf("expected the counter to continue after the existing files, got %q (%d)", name, seq)
+	}
+	_, nine := next()
+	_, ten := next()
+	// A number given back in the middle is reused, one at the end is taken back
+	planner.releaseSeq(folder, nine, "")
+	if _, seq := next(); seq != 9 {
+		t.Fatalf("expected the released number 9 to be reused, got %d", seq)
+	}
+	planner.releaseSeq(folder, ten, "")
+	if _, seq := next(); seq != 10 {
+		t.Fatalf("expected the released last number 10 to be reused, got %d", seq)
+	}
+	if _, seq := next(); seq != 11 {
+		t.Fatalf("expected 11 after the reused numbers, got %d", seq)
+	}
+}
+
+func TestHighestSeqUsesTokenWidths(t *testing.T) {
+	for _, tc := range []struct {
+		rename string
+		files  []string
+		want   int
+	}{
+		// Adjacent date and sequence tokens split at the date width
+		{"{yyyyMMdd}{seq:4}", []string{"202406010012.jpg", "202406010003.jpg"}, 12},
+		{"{HHmmss}{seq:2}{orig}", []string{"14223307IMG_0001.CR3"}, 7},
+		// Unrelated files whose numbers do not have the sequence width are ignored
+		{"{orig}_{seq:2}", []string{"IMG_0001_04.jpg", "vacation_9999.jpg", "notes.txt"}, 4},
+		{"{date}_{seq}", []string{"2024-06-03_5.jpg", "2024-6-3_77.jpg", "2024-06-03_x.jpg"}, 5},
+	} {
+		folder := t.TempDir()
+		for _, name := range tc.files {
+			mustWriteFile(t, filepath.Join(folder, name), name)
+		}
+		rename, err := parseRename(tc.rename)
+		if err != nil {
+			t.Fatalf("parseRename(%q) returned error: %v", tc.rename, err)
+		}
+		if got := highestSeq(folder, rename); got != tc.want {
+			t.Fatalf("%s: expected highest sequence %d, got %d", tc.rename, tc.want, got)
+		}
+	}
+}
+
+func TestRenameRendersSubSecondTokens(t *testing.T) {
+	planner, err := newDestinationPlanner(Options{To: "/lib", Rename: "{HHmmss}{SSS}_{ns}"})
+	if err != nil {
+		t.Fatalf("newDestinationPlanner returned error: %v", err)
+	}
+	meta := FileMeta{Timestamp: time.Date(2024, 6, 3, 14, 22, 33, 45_000_000, time.UTC)}
+	_, name, _ := planner.destination(importJob{rel: "IMG_0001.CR3", info: fakeFileInfo(t, "IMG_0001.CR3")}, meta)
+	if name != "142233045_045000000.CR3" {
+		t.Fatalf("unexpected name: %q", name)
+	}
+	if _, err := parseLayout("{date}/{SSS}"); err == nil {
+		t.Fatal("expected {SSS} to be a rename-only token")
+	}
+}
@@ -0,0 +1,137 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVerifyAndReorganizeLibrary(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	for name, mtime := range map[string]time.Time{
+		"a.jpg": time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local),
+		"b.jpg": time.Date(2023, 1, 2, 3, 4, 5, 0, time.Local),
+	} {
+		path := filepath.Join(from, name)
+		mustWriteFile(t, path, name)
+		mustSetMtime(t, path, mtime)
+	}
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+		UseModTime: true,
+	}
+	var out bytes.Buffer
+	if _, err := runImport(context.Background(), cfg, &out, nil); err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+
+	out.Reset()
+	reorganized, err := Reorganize(ReorganizeOptions{To: to, Layout: "{yyyy}/{mm}", UseModTime: true}, &out)
+	if err != nil {
+		t.Fatalf("Reorganize returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if reorganized.Moved != 2 || reorganized.DirsRemoved != 2 {
+		t.Fatalf("expected 2 moved files and 2 removed folders, got: %+v", reorganized)
+	}
+	for _, rel := range []string{"2024/05/a.jpg", "2023/01/b.jpg"} {
+		if _, err := os.Stat(filepath.Join(to, filepath.FromSlash(rel))); err != nil {
+			t.Fatalf("expected %s after reorganize: %v", rel, err)
+		}
+	}
+
+	out.Reset()
+	verified, err := Verify(VerifyOptions{To: to, MaxWorkers: 2}, &out)
+	if err != nil || verified.OK != 2 {
+		t.Fatalf("expected both moved files to verify, got %+v, %v\noutput:\n%s", verified, err, out.String())
+	}
+
+	mustWriteFile(t, filepath.Join(to, "2024", "05", "a.jpg"), "tampered")
+	if err := os.Remove(filepath.Join(to, "2023", "01", "b.jpg")); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	out.Reset()
+	verified, err = Verify(VerifyOptions{To: to, MaxWorkers: 2}, &out)
+	if err == nil || verified.Changed != 1 || verified.Missing != 1 {
+		t.Fatalf("expected one changed and one missing file, got %+v, %v\noutput:\n%s", verified, err, out.String())
+	}
+}
+
+func TestVerifyDefaultsWorkers(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	path := filepath.Join(from, "a.jpg")
+	mustWriteFile(t, path, "a")
+	mustSetMtime(t, path, time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local))
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+		UseModTime: true,
+	}
+	var out bytes.Buffer
+	if _, err := runImport(context.Background(), cfg, &out, nil); err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+
+	done := make(chan struct{})
+	var verified VerifySummary
+	var err error
+	go func() {
+		defer close(done)
+		verified, err = Verify(VerifyOptions{To: to}, io.Discard)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Verify with zero-value options did not return")
+	}
+	if err != nil || verified.OK != 1 {
+		t.Fatalf("expected one verified file, got %+v, %v", verified, err)
+	}
+}
+
+func TestRunStatsCountsLibraryFiles(t *testing.T) {
+	to := t.TempDir()
+	for rel, content := range map[string]string{
+		"2024/a.jpg":           "aaaa",
+		"2024/b.JPG":           "bb",
+		"2023/c.cr3":           "c",
+		".file-importer/x.txt": "ignored",
+	} {
+		path := filepath.Join(to, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		mustWriteFile(t, path, content)
+		mustSetMtime(t, path, time.Date(2022, 6, 1, 12, 0, 0, 0, time.Local))
+	}
+
+	var out bytes.Buffer
+	summary, err := Stats(StatsOptions{To: to}, &out)
+	if err != nil {
+		t.Fatalf("Stats returned error: %v", err)
+	}
+	if summary.Total.Files != 3 || summary.Total.Bytes != 7 {
+		t.Fatalf("expected 3 files with 7 bytes, got: %+v", summary.Total)
+	}
+	if summary.ByExt["jpg"].Files != 2 || summary.ByYear["2022"].Files != 3 {
+		t.Fatalf("unexpected grouping: jpg=%+v 2022=%+v", summary.ByExt["jpg"], summary.ByYear["2022"])
+	}
+}
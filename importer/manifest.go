@@ -0,0 +1,259 @@
+package importer
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const runsDirName = "runs"
+
+const backupsDirName = "backups"
+
+// manifestFile records a file created by a run, with paths relative to the destination root
+type manifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Source string `json:"source"`
+	// Moved is set when the source was deleted after the copy
+	Moved bool `json:"moved,omitempty"`
+	// Overwrote is set when the file replaced an existing one, Backup holds where that one
+	// was kept (empty when --keep-backups was not set)
+	Overwrote bool   `json:"overwrote,omitempty"`
+	Backup    string `json:"backup,omitempty"`
+	// Undone is set once undo removed the file, so a retry after kept files skips it
+	Undone bool `json:"undone,omitempty"`
+}
+
+// manifestLogEntry is one line of the log a running import appends to as files land. It is
+// folded into the manifest when the run finishes, or when the manifest of a run that never
+// finished is loaded.
+type manifestLogEntry struct {
+	File        *manifestFile `json:"file,omitempty"`
+	CreatedDirs []string      `json:"created_dirs,omitempty"`
+}
+
+// runManifest lists everything a run changed below the destination root so 'undo' can
+// revert exactly that run
+type runManifest struct {
+	ID          string         `json:"id"`
+	From        string         `json:"from"`
+	To          string         `json:"to"`
+	Started     time.Time      `json:"started"`
+	Finished    time.Time      `json:"finished"`
+	Files       []manifestFile `json:"files"`
+	CreatedDirs []string       `json:"created_dirs"`
+	Undone      *time.Time     `json:"undone,omitempty"`
+
+	dirs map[string]bool
+	log  *os.File
+}
+
+// Generate a run id that sorts by start time, e.g. 20240603-142233-9f1c
+func newRunID(now time.Time) string {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+func newRunManifest(cfg Options, id string) *runManifest {
+	return &runManifest{ID: id, From: cfg.From, To: cfg.To, Started: time.Now(), Files: []manifestFile{}, dirs: make(map[string]bool)}
+}
+
+// Write the empty manifest and open its log, so a run that is killed can still be undone
+func (m *runManifest) start() error {
+	if err := m.save(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(manifestLogPath(m.To, m.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	m.log = f
+	return nil
+}
+
+// Record a finished file if the run created it, appending it to the log when the run was
+// started. Callers serialize access.
+func (m *runManifest) add(result FileResult) error {
+	var entry manifestLogEntry
+	for _, dir := range result.createdDirs {
+		rel, err := filepath.Rel(m.To, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if !m.dirs[rel] {
+			m.dirs[rel] = true
+			m.CreatedDirs = append(m.CreatedDirs, filepath.ToSlash(rel))
+			entry.CreatedDirs = append(entry.CreatedDirs, filepath.ToSlash(rel))
+		}
+	}
+	if !result.written {
+		return m.appendLog(entry)
+	}
+	if rel, err := filepath.Rel(m.To, result.Destination); err == nil {
+		file := manifestFile{
+			Path:      filepath.ToSlash(rel),
+			SHA256:    result.SHA256,
+			Source:    result.Source,
+			Moved:     result.Outcome == OutcomeMoved,
+			Overwrote: result.overwrote,
+		}
+		if result.backup != "" {
+			if backup, err := filepath.Rel(m.To, result.backup); err == nil {
+				file.Backup = filepath.ToSlash(backup)
+			}
+		}
+		m.Files = append(m.Files, file)
+		entry.File = &file
+	}
+	return m.appendLog(entry)
+}
+
+// Append an entry to the log of a started run
+func (m *runManifest) appendLog(entry manifestLogEntry) error {
+	if m.log == nil || (entry.File == nil && len(entry.CreatedDirs) == 0) {
+		return nil
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = m.log.Write(append(b, '\n'))
+	return err
+}
+
+// Close the log of a started run. save removes it once the manifest holds its entries.
+func (m *runManifest) close() error {
+	if m.log == nil {
+		return nil
+	}
+	err := m.log.Close()
+	m.log = nil
+	return err
+}
+
+// Fold the log of a run that never finished into the manifest
+func (m *runManifest) replayLog() error {
+	f, err := os.Open(manifestLogPath(m.To, m.ID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dirs := make(map[string]bool)
+	for _, dir := range m.CreatedDirs {
+		dirs[dir] = true
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry manifestLogEntry
+		// A torn last line from a killed run is simply ignored
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		for _, dir := range entry.CreatedDirs {
+			if !dirs[dir] {
+				dirs[dir] = true
+				m.CreatedDirs = append(m.CreatedDirs, dir)
+			}
+		}
+		if entry.File != nil {
+			m.Files = append(m.Files, *entry.File)
+		}
+	}
+	return scanner.Err()
+}
+
+func manifestPath(root, id string) string {
+	return filepath.Join(root, metaDirName, runsDirName, id+".json")
+}
+
+func manifestLogPath(root, id string) string {
+	return filepath.Join(root, metaDirName, runsDirName, id+".jsonl")
+}
+
+// Write the manifest below the destination root. A log left next to it is removed once the
+// manifest holds its entries, except while the run is still appending to it.
+func (m *runManifest) save() error {
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	sort.Strings(m.CreatedDirs)
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := manifestPath(m.To, m.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if m.log != nil {
+		return nil
+	}
+	if err := os.Remove(manifestLogPath(m.To, m.ID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Load the manifest of a run from the destination root
+func loadRunManifest(root, id string) (*runManifest, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid run id %q", id)
+	}
+	b, err := os.ReadFile(manifestPath(root, id))
+	if err != nil {
+		return nil, err
+	}
+	var m runManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("read manifest %s failed: %w", id, err)
+	}
+	// The manifest may have been written under a different spelling of the root
+	m.To = root
+	if err := m.replayLog(); err != nil {
+		return nil, fmt.Errorf("read manifest log %s failed: %w", id, err)
+	}
+	return &m, nil
+}
+
+// Create path and any missing parents like os.MkdirAll, returning the directories that did not
+// exist before, outermost first
+func mkdirAllTracked(path string) ([]string, error) {
+	var missing []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		missing = append(missing, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(missing)-1; i < j; i, j = i+1, j-1 {
+		missing[i], missing[j] = missing[j], missing[i]
+	}
+	return missing, nil
+}
@@ -0,0 +1,258 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TIFF tags read from RAW files and the EXIF blocks of videos
+const (
+	tagMake               = 0x010f
+	tagModel              = 0x0110
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTime         = 0x9010
+	tagOffsetTimeOriginal = 0x9011
+	tagSubSecTimeOriginal = 0x9291
+	tagBodySerialNumber   = 0xa431
+	tagLensModel          = 0xa434
+
+	// Tags of the GPS IFD, whose numbers no other IFD read here uses
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+)
+
+// Headers of TIFF-based RAW files. NEF, ARW, DNG and CR2 are plain TIFF, Olympus ORF and
+// Panasonic RW2 put their own marker where TIFF has 42.
+var rawTIFFHeaders = [][]byte{
+	[]byte("II*\x00"), []byte("MM\x00*"),
+	[]byte("IIRO"), []byte("IIRS"), []byte("MMOR"),
+	[]byte("IIU\x00"),
+}
+
+// Fujifilm RAF files start with this and keep their EXIF in an embedded JPEG whose offset is
+// a big-endian uint32 at byte 84
+const rafMagic = "FUJIFILMCCD-RAW"
+
+// Limits that keep a corrupt file from being read at length
+const (
+	maxTIFFEntries = 1024
+	maxTIFFASCII   = 1024
+)
+
+// Read the EXIF tags of a RAW file with a decoder for its container. ok is false for files
+// that are not RAW, could not be decoded or have no DateTimeOriginal where this decoder
+// looks, which are left to the generic EXIF scan.
+func readRAWTags(r io.ReaderAt, size int64) (tags *exifTags, ok bool) {
+	head := make([]byte, 92)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+
+	var values map[uint16]string
+	var err error
+	switch {
+	case bytes.HasPrefix(head, []byte(rafMagic)):
+		values, err = readRAFTags(r, size, head)
+	case hasRAWTIFFHeader(head):
+		values, err = readTIFFTags(r, 0, size)
+	default:
+		return nil, false
+	}
+	if err != nil || values[tagDateTimeOriginal] == "" {
+		return nil, false
+	}
+	return &exifTags{
+		dateTimeOriginal:   values[tagDateTimeOriginal],
+		offsetTimeOriginal: values[tagOffsetTimeOriginal],
+		offsetTime:         values[tagOffsetTime],
+		subSecTimeOriginal: values[tagSubSecTimeOriginal],
+		make:               values[tagMake],
+		model:              values[tagModel],
+		lens:               values[tagLensModel],
+		serial:             values[tagBodySerialNumber],
+		gpsLatitude:        values[tagGPSLatitude],
+		gpsLatitudeRef:     values[tagGPSLatitudeRef],
+		gpsLongitude:       values[tagGPSLongitude],
+		gpsLongitudeRef:    values[tagGPSLongitudeRef],
+	}, true
+}
+
+func hasRAWTIFFHeader(head []byte) bool {
+	for _, h := range rawTIFFHeaders {
+		if bytes.HasPrefix(head, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Read the EXIF of a RAF file from the APP1 segment of its embedded JPEG
+func readRAFTags(r io.ReaderAt, size int64, head []byte) (map[uint16]string, error) {
+	if len(head) < 92 {
+		return nil, fmt.Errorf("RAF header too short")
+	}
+	offset := int64(binary.BigEndian.Uint32(head[84:]))
+	length := int64(binary.BigEndian.Uint32(head[88:]))
+	if offset <= 0 || offset+4 > size {
+		return nil, fmt.Errorf("invalid RAF JPEG offset %d", offset)
+	}
+	if length <= 0 || offset+length > size {
+		length = size - offset
+	}
+
+	// Walk the JPEG segments up to the image data
+	var marker [4]byte
+	if _, err := r.ReadAt(marker[:2], offset); err != nil || marker[0] != 0xff || marker[1] != 0xd8 {
+		return nil, fmt.Errorf("no JPEG at RAF offset %d", offset)
+	}
+	end := offset + length
+	for off := offset + 2; off+4 <= end; {
+		if _, err := r.ReadAt(marker[:], off); err != nil {
+			return nil, err
+		}
+		if marker[0] != 0xff || marker[1] == 0xda {
+			break
+		}
+		segment := int64(binary.BigEndian.Uint16(marker[2:]))
+		if segment < 2 || off+2+segment > end {
+			break
+		}
+		if marker[1] == 0xe1 && segment > 8 {
+			payload := make([]byte, segment-2)
+			if _, err := r.ReadAt(payload, off+4); err != nil {
+				return nil, err
+			}
+			if tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00")); ok {
+				return readTIFFTags(bytes.NewReader(tiff), 0, int64(len(tiff)))
+			}
+		}
+		off += 2 + segment
+	}
+	return nil, fmt.Errorf("no EXIF in RAF JPEG")
+}
+
+// Read the ASCII tags of IFD0, the Exif IFD and the GPS IFD of the TIFF structure at base.
+// GPS coordinates are returned as their three rationals in decimal, e.g. "35 39 29.16".
+// Offsets in the structure are relative to base.
+func readTIFFTags(r io.ReaderAt, base, size int64) (map[uint16]string, error) {
+	var head [8]byte
+	if _, err := r.ReadAt(head[:], base); err != nil {
+		return nil, err
+	}
+	t := tiffReader{r: r, base: base, size: size}
+	switch string(head[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("no TIFF byte order mark")
+	}
+
+	values := make(map[uint16]string)
+	exifIFD, gpsIFD, err := t.readIFD(int64(t.order.Uint32(head[4:])), values)
+	if err != nil {
+		return nil, err
+	}
+	if exifIFD > 0 {
+		if _, _, err := t.readIFD(exifIFD, values); err != nil {
+			return nil, err
+		}
+	}
+	if gpsIFD > 0 {
+		// A broken GPS IFD leaves the timestamps usable
+		gps := make(map[uint16]string)
+		if _, _, err := t.readIFD(gpsIFD, gps); err == nil {
+			for _, tag := range []uint16{tagGPSLatitudeRef, tagGPSLatitude, tagGPSLongitudeRef, tagGPSLongitude} {
+				if v, ok := gps[tag]; ok {
+					values[tag] = v
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// tiffReader reads IFDs of a TIFF structure at base in r
+type tiffReader struct {
+	r     io.ReaderAt
+	base  int64
+	size  int64
+	order binary.ByteOrder
+}
+
+// Add the ASCII entries and rational triples of the IFD at off to values and return the
+// Exif and GPS IFD offsets it points to, 0 when it has none
+func (t tiffReader) readIFD(off int64, values map[uint16]string) (exifIFD, gpsIFD int64, err error) {
+	if off < 8 || off+2 > t.size {
+		return 0, 0, fmt.Errorf("invalid IFD offset %d", off)
+	}
+	var count [2]byte
+	if _, err := t.r.ReadAt(count[:], t.base+off); err != nil {
+		return 0, 0, err
+	}
+	n := int64(t.order.Uint16(count[:]))
+	if n > maxTIFFEntries || off+2+12*n > t.size {
+		return 0, 0, fmt.Errorf("invalid IFD at offset %d", off)
+	}
+	entries := make([]byte, 12*n)
+	if _, err := t.r.ReadAt(entries, t.base+off+2); err != nil {
+		return 0, 0, err
+	}
+
+	for i := int64(0); i < n; i++ {
+		e := entries[12*i : 12*i+12]
+		tag, typ, count := t.order.Uint16(e), t.order.Uint16(e[2:]), int64(t.order.Uint32(e[4:]))
+		switch {
+		case tag == tagExifIFD && (typ == 4 || typ == 13):
+			exifIFD = int64(t.order.Uint32(e[8:]))
+		case tag == tagGPSIFD && (typ == 4 || typ == 13):
+			gpsIFD = int64(t.order.Uint32(e[8:]))
+		case typ == 5 && count == 3:
+			// Three unsigned rationals, such as degrees, minutes and seconds
+			valueOff := int64(t.order.Uint32(e[8:]))
+			if valueOff+24 > t.size {
+				continue
+			}
+			var raw [24]byte
+			if _, err := t.r.ReadAt(raw[:], t.base+valueOff); err != nil {
+				continue
+			}
+			parts := make([]string, 3)
+			for j := range parts {
+				num, den := t.order.Uint32(raw[8*j:]), t.order.Uint32(raw[8*j+4:])
+				parts[j] = formatRational(num, den)
+			}
+			values[tag] = strings.Join(parts, " ")
+		case typ == 2 && count > 0 && count <= maxTIFFASCII:
+			value := e[8 : 8+min(count, 4)]
+			if count > 4 {
+				valueOff := int64(t.order.Uint32(e[8:]))
+				if valueOff+count > t.size {
+					continue
+				}
+				value = make([]byte, count)
+				if _, err := t.r.ReadAt(value, t.base+valueOff); err != nil {
+					continue
+				}
+			}
+			values[tag] = strings.TrimRight(string(value), "\x00 ")
+		}
+	}
+	return exifIFD, gpsIFD, nil
+}
+
+// Format a rational in decimal, "0" when the denominator is 0
+func formatRational(num, den uint32) string {
+	if den == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(num)/float64(den), 'f', -1, 64)
+}
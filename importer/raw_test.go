@@ -0,0 +1,95 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withMagic replaces the TIFF magic number after the byte order mark
+func withMagic(tiff []byte, magic string) []byte {
+	out := append([]byte(nil), tiff...)
+	copy(out[2:4], magic)
+	return out
+}
+
+func mustReadFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "raw", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
+
+func TestRAWFormatsResolveToDateTimeOriginal(t *testing.T) {
+	nef := mustReadFixture(t, "nikon_d1.nef")
+	arw := mustReadFixture(t, "sony_a330.arw")
+	cr2 := mustReadFixture(t, "canon_s90.cr2")
+	nikon := time.Date(2000, 11, 19, 13, 1, 50, 240*int(time.Millisecond), time.UTC)
+	sony := time.Date(2009, 11, 13, 13, 33, 25, 0, time.UTC)
+	canon := time.Date(2011, 8, 24, 14, 41, 4, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		want        time.Time
+		make, model string
+		lens        string
+	}{
+		// SubIFDs of IFD0 point past the cut and are not needed for the Exif IFD
+		{"DSC_0001.NEF", nef, nikon, "NIKON CORPORATION", "NIKON D1", ""},
+		{"DSC00001.ARW", arw, sony, "SONY", "DSLR-A330", ""},
+		{"IMG_0001.DNG", mustReadFixture(t, "canon_sd450.dng"), time.Date(2009, 3, 30, 19, 25, 18, 0, time.UTC), "Canon", "Canon PowerShot SD450", ""},
+		{"IMG_0001.CR2", cr2, canon, "Canon", "Canon PowerShot S90", ""},
+		// The EXIF of a RAF is in the JPEG at the offset of its header
+		{"DSCF0001.RAF", mustReadFixture(t, "fujifilm_xh1.raf"), time.Date(2019, 1, 9, 5, 55, 40, 0, time.FixedZone("", 8*3600)), "FUJIFILM", "X-H1", "XF16mmF1.4 R WR"},
+		// This RW2 has no Exif IFD in IFD0, so the generic scan finds the EXIF of its preview
+		{"P1000001.RW2", mustReadFixture(t, "panasonic_s5.rw2"), time.Date(2022, 10, 6, 8, 14, 30, 715*int(time.Millisecond), time.FixedZone("", -8*3600)), "Panasonic", "DC-S5", "LUMIX S 24-105/F4"},
+		// ORF headers in both byte orders
+		{"P6010001.ORF", withMagic(arw, "RO"), sony, "SONY", "DSLR-A330", ""},
+		{"P6010002.ORF", withMagic(cr2, "RS"), canon, "Canon", "Canon PowerShot S90", ""},
+		{"P6010003.ORF", withMagic(nef, "OR"), nikon, "NIKON CORPORATION", "NIKON D1", ""},
+	}
+	mtime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			mustWriteBytes(t, path, tt.data)
+			mustSetMtime(t, path, mtime)
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("stat failed: %v", err)
+			}
+			meta, err := resolveTimestamp(context.Background(), timestampConfig{location: time.UTC}, path, fi, func(string, ...any) {})
+			if err != nil {
+				t.Fatalf("resolveTimestamp returned error: %v", err)
+			}
+			// Compare the formatted times so that the offset has to match too
+			if meta.Source != TimeSourceExif || meta.Timestamp.Format(time.RFC3339Nano) != tt.want.Format(time.RFC3339Nano) {
+				t.Fatalf("expected DateTimeOriginal %v from EXIF, got: %+v", tt.want, meta)
+			}
+			if meta.Make != tt.make || meta.Model != tt.model || meta.Lens != tt.lens {
+				t.Fatalf("expected %q %q %q from EXIF, got: %+v", tt.make, tt.model, tt.lens, meta)
+			}
+		})
+	}
+}
+
+func TestReadRAWTagsLeavesOtherFilesToTheEXIFScan(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"jpeg":                          buildJPEG(buildTIFF(nil, nil, nil)),
+		"text":                          []byte("II is not a TIFF"),
+		"short RAF":                     []byte(rafMagic),
+		"TIFF without DateTimeOriginal": buildTIFF([]tiffEntry{asciiEntry(0x010f, "Maker")}, nil, nil),
+		"RW2 with EXIF in its preview":  mustReadFixture(t, "panasonic_s5.rw2"),
+	} {
+		if _, ok := readRAWTags(bytes.NewReader(data), int64(len(data))); ok {
+			t.Fatalf("%s: expected readRAWTags to decline", name)
+		}
+	}
+}
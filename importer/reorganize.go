@@ -0,0 +1,219 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReorganizeOptions select the library and the layout Reorganize moves its files into
+type ReorganizeOptions struct {
+	To         string
+	Layout     string
+	Rename     string
+	UseModTime bool
+	DryRun     bool
+	// TimeOptions date the files unless UseModTime is set
+	TimeOptions
+}
+
+// ReorganizeSummary counts the files Reorganize looked at
+type ReorganizeSummary struct {
+	Checked     int
+	Moved       int
+	Unchanged   int
+	Failed      int
+	DirsRemoved int
+}
+
+// List the regular files below root, skipping hidden files and folders (including the
+// importer's own state), as slash-separated paths relative to root in lexical order
+func listLibraryFiles(root string) ([]importJob, error) {
+	var jobs []importJob
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, importJob{rel: rel, info: info})
+		return nil
+	})
+	return jobs, err
+}
+
+// Move the files of an existing library to where --layout and --rename would put them today.
+// Manifests are updated so 'verify' and 'undo' keep working on the moved files.
+func Reorganize(cfg ReorganizeOptions, out io.Writer) (ReorganizeSummary, error) {
+	var summary ReorganizeSummary
+	planner, err := newDestinationPlanner(Options{To: cfg.To, Layout: cfg.Layout, Rename: cfg.Rename, TimeOptions: TimeOptions{FolderTimeZone: cfg.FolderTimeZone}})
+	if err != nil {
+		return summary, err
+	}
+	jobs, err := listLibraryFiles(cfg.To)
+	if err != nil {
+		return summary, fmt.Errorf("list %s failed: %w", cfg.To, err)
+	}
+	logf := func(format string, args ...any) {
+		fmt.Fprintf(out, format+"\n", args...)
+	}
+	tc := cfg.timestampConfig()
+
+	// Files keep their place when nothing changes, so claim every current path first
+	for _, job := range jobs {
+		planner.claim(filepath.Join(cfg.To, job.rel))
+	}
+
+	renamed := make(map[string]string) // old -> new, slash-separated and relative to the root
+	oldDirs := make(map[string]bool)
+	for _, job := range jobs {
+		summary.Checked++
+		from := filepath.Join(cfg.To, job.rel)
+		meta := FileMeta{Timestamp: job.info.ModTime(), Source: TimeSourceModTime, Confidence: modTimeConfidence}
+		if !cfg.UseModTime {
+			meta, _ = resolveTimestamp(context.Background(), tc, from, job.info, logf)
+		}
+		folder, name, _ := planner.destination(job, meta)
+		to := filepath.Join(folder, name)
+		if to == from {
+			summary.Unchanged++
+			continue
+		}
+		if !planner.claim(to) {
+			if to, err = planner.claimAlternative(folder, name); err != nil {
+				logf("%s: %v", job.rel, err)
+				summary.Failed++
+				continue
+			}
+		} else if _, err := os.Lstat(to); err == nil {
+			if to, err = planner.claimAlternative(folder, name); err != nil {
+				logf("%s: %v", job.rel, err)
+				summary.Failed++
+				continue
+			}
+		}
+		display := relativeFolder(cfg.To, to)
+		if cfg.DryRun {
+			logf("Would move %s -> %s", filepath.ToSlash(job.rel), display)
+			summary.Moved++
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+			logf("%s: create folder failed: %v", job.rel, err)
+			summary.Failed++
+			continue
+		}
+		if err := os.Rename(from, to); err != nil {
+			logf("%s: move failed: %v", job.rel, err)
+			summary.Failed++
+			continue
+		}
+		logf("Moved %s -> %s", filepath.ToSlash(job.rel), display)
+		summary.Moved++
+		renamed[filepath.ToSlash(job.rel)] = display
+		oldDirs[filepath.Dir(from)] = true
+	}
+
+	if !cfg.DryRun {
+		dirs := make([]string, 0, len(oldDirs))
+		for dir := range oldDirs {
+			for ; dir != filepath.Clean(cfg.To) && strings.HasPrefix(dir, filepath.Clean(cfg.To)); dir = filepath.Dir(dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+		summary.DirsRemoved = removeEmptyDirs(dirs)
+		if err := renameInManifests(cfg.To, renamed); err != nil {
+			return summary, fmt.Errorf("update run manifests failed: %w", err)
+		}
+	}
+
+	verb := "Reorganized"
+	if cfg.DryRun {
+		verb = "Dry run"
+	}
+	fmt.Fprintf(
+		out,
+		"%s. checked=%d moved=%d unchanged=%d failed=%d folders_removed=%d\n",
+		verb,
+		summary.Checked,
+		summary.Moved,
+		summary.Unchanged,
+		summary.Failed,
+		summary.DirsRemoved,
+	)
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("reorganize completed with %d failures", summary.Failed)
+	}
+	return summary, nil
+}
+
+// Point the file entries of all run manifests at the new paths of moved files
+func renameInManifests(root string, renamed map[string]string) error {
+	if len(renamed) == 0 {
+		return nil
+	}
+	manifests, err := listRunManifests(root)
+	if err != nil {
+		return err
+	}
+	for _, m := range manifests {
+		changed := false
+		for i, f := range m.Files {
+			if to, ok := renamed[f.Path]; ok {
+				m.Files[i].Path = to
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := m.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load every run manifest below root, oldest run first
+func listRunManifests(root string) ([]*runManifest, error) {
+	entries, err := os.ReadDir(filepath.Join(root, metaDirName, runsDirName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifests []*runManifest
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		m, err := loadRunManifest(root, id)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
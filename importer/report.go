@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// reportEntry is the JSON form of a FileResult
+type reportEntry struct {
+	Source              string  `json:"source"`
+	Destination         string  `json:"destination,omitempty"`
+	Timestamp           string  `json:"timestamp,omitempty"`
+	TimestampSource     string  `json:"timestamp_source,omitempty"`
+	TimestampConfidence float64 `json:"timestamp_confidence,omitempty"`
+	TimestampReason     string  `json:"timestamp_reason,omitempty"`
+	ClockShift          string  `json:"clock_shift,omitempty"`
+	Bytes               int64   `json:"bytes"`
+	DurationMs          float64 `json:"duration_ms"`
+	Outcome             string  `json:"outcome"`
+	Conflict            bool    `json:"conflict,omitempty"`
+	SourceDeleted       bool    `json:"source_deleted,omitempty"`
+	Error               string  `json:"error,omitempty"`
+}
+
+// reportSummary is the JSON form of a Summary
+type reportSummary struct {
+	Processed  int `json:"processed"`
+	Copied     int `json:"copied"`
+	Skipped    int `json:"skipped"`
+	Failed     int `json:"failed"`
+	Conflicts  int `json:"conflicts"`
+	Duplicates int `json:"duplicates"`
+	Moved      int `json:"moved"`
+	Resumed    int `json:"resumed"`
+}
+
+// runReport collects the machine-readable record of a run written by --report
+type runReport struct {
+	RunID       string        `json:"run_id"`
+	From        string        `json:"from"`
+	To          string        `json:"to"`
+	DryRun      bool          `json:"dry_run"`
+	Interrupted bool          `json:"interrupted,omitempty"`
+	Started     time.Time     `json:"started"`
+	Finished    time.Time     `json:"finished"`
+	Summary     reportSummary `json:"summary"`
+	Files       []reportEntry `json:"files"`
+}
+
+func newRunReport(cfg Options, runID string) *runReport {
+	return &runReport{RunID: runID, From: cfg.From, To: cfg.To, DryRun: cfg.DryRun, Started: time.Now(), Files: []reportEntry{}}
+}
+
+// Record a finished file. Callers serialize access.
+func (r *runReport) add(result FileResult) {
+	entry := reportEntry{
+		Source:              result.Source,
+		Destination:         result.Destination,
+		TimestampSource:     result.Meta.Source,
+		TimestampConfidence: result.Meta.Confidence,
+		TimestampReason:     result.Meta.Reason,
+		Bytes:               result.Bytes,
+		DurationMs:          float64(result.Duration.Microseconds()) / 1000,
+		Outcome:             result.Outcome,
+		Conflict:            result.Conflict,
+		SourceDeleted:       result.Outcome == OutcomeMoved,
+	}
+	if !result.Meta.Timestamp.IsZero() {
+		entry.Timestamp = result.Meta.Timestamp.Format(time.RFC3339Nano)
+	}
+	if result.Meta.ClockShift != 0 {
+		entry.ClockShift = formatShift(result.Meta.ClockShift)
+	}
+	if result.Err != nil {
+		entry.Error = result.Err.Error()
+	}
+	r.Files = append(r.Files, entry)
+}
+
+// Write the report with the final summary to path
+func (r *runReport) write(path string, summary Summary) error {
+	r.Finished = time.Now()
+	r.Interrupted = summary.Interrupted
+	r.Summary = reportSummary{
+		Processed:  summary.Processed,
+		Copied:     summary.Copied,
+		Skipped:    summary.Skipped,
+		Failed:     summary.Failed,
+		Conflicts:  summary.Conflicts,
+		Duplicates: summary.Duplicates,
+		Moved:      summary.Moved,
+		Resumed:    summary.Resumed,
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
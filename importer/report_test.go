@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunImportWritesJSONReport(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+
+	inRange := filepath.Join(from, "in_range.jpg")
+	outOfRange := filepath.Join(from, "out_range.jpg")
+	mustWriteFile(t, inRange, "in range")
+	mustWriteFile(t, outOfRange, "out of range")
+	mustSetMtime(t, inRange, time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC))
+	mustSetMtime(t, outOfRange, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+
+	reportPath := filepath.Join(root, "report.json")
+	cfg := Options{
+		From:       from,
+		To:         to,
+		Start:      time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		End:        time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+		Report:     reportPath,
+	}
+
+	var out bytes.Buffer
+	if _, err := runImport(context.Background(), cfg, &out, nil); err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+
+	var report runReport
+	if err := json.Unmarshal([]byte(readFileString(t, reportPath)), &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if report.Summary.Processed != 2 || report.Summary.Copied != 1 || report.Summary.Skipped != 1 {
+		t.Fatalf("unexpected report summary: %+v", report.Summary)
+	}
+	if len(report.Files) != 2 {
+		t.Fatalf("expected two file entries, got %d", len(report.Files))
+	}
+
+	entries := map[string]reportEntry{}
+	for _, e := range report.Files {
+		entries[filepath.Base(e.Source)] = e
+	}
+	copied := entries["in_range.jpg"]
+	if copied.Outcome != OutcomeCopied || copied.TimestampSource != TimeSourceModTime || copied.Bytes != 8 ||
+		copied.TimestampConfidence != modTimeConfidence || copied.TimestampReason != "no EXIF data found, using ModTime" {
+		t.Fatalf("unexpected entry for copied file: %+v", copied)
+	}
+	if copied.Destination != filepath.Join(to, "2024-03-05-jpg", "in_range.jpg") {
+		t.Fatalf("unexpected destination: %q", copied.Destination)
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, copied.Timestamp); err != nil || !ts.Equal(time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected timestamp %q (err=%v)", copied.Timestamp, err)
+	}
+	if got := entries["out_range.jpg"].Outcome; got != OutcomeOutOfRange {
+		t.Fatalf("expected out-of-range outcome, got %q", got)
+	}
+}
@@ -0,0 +1,489 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/evanoberholster/imagemeta"
+)
+
+// TimestampResolver finds when a file was captured from one kind of evidence. The resolvers
+// of a chain are tried in order until one returns a timestamp.
+type TimestampResolver interface {
+	// Name identifies the resolver in --time-source and is recorded as the source of the
+	// timestamps it finds
+	Name() string
+	// Resolve returns a zero Timestamp when the file has no evidence of this kind. An error
+	// is logged and the next resolver is tried.
+	Resolve(ctx context.Context, f *SourceFile) (Resolution, error)
+}
+
+// Resolution is the timestamp a resolver found for a file
+type Resolution struct {
+	Timestamp time.Time
+	// Confidence from 0 to 1 that Timestamp is the capture time
+	Confidence float64
+	// Reason says which piece of evidence the timestamp came from
+	Reason string
+}
+
+// SourceFile is the file a TimestampResolver looks at. Metadata parsed by one resolver is kept
+// so the next one does not read the file again.
+type SourceFile struct {
+	Path string
+	Info os.FileInfo
+	// Location is the time zone of timestamps recorded without an offset, nil means local time
+	Location *time.Location
+
+	openErr error
+	exif    *exifTags
+	cr3     *cr3Tags
+	video   *videoTags
+}
+
+// exifTags are the EXIF values used to date a file, empty when the file has no EXIF
+type exifTags struct {
+	dateTimeOriginal   string
+	offsetTimeOriginal string
+	offsetTime         string
+	subSecTimeOriginal string
+	make, model, lens  string
+	serial             string
+	// gpsLatitude and gpsLongitude are degrees, minutes and seconds in decimal, e.g.
+	// "35 39 29.16", the refs are N/S and E/W
+	gpsLatitude, gpsLatitudeRef   string
+	gpsLongitude, gpsLongitudeRef string
+}
+
+// cr3Tags are the values imagemeta decodes from a CR3 file. decoded is false for other files.
+type cr3Tags struct {
+	decoded          bool
+	dateTimeOriginal time.Time
+	// naive is set when DateTimeOriginal has no offset, imagemeta then returns it in UTC
+	naive                     bool
+	make, model, lens         string
+	serial                    string
+	gpsLatitude, gpsLongitude float64
+}
+
+// The zone naive timestamps of the file are read in
+func (f *SourceFile) location() *time.Location {
+	if f.Location == nil {
+		return time.Local
+	}
+	return f.Location
+}
+
+// Describe how naive timestamps were read, for the reason of a Resolution
+func (f *SourceFile) readAs() string {
+	if f.location() == time.Local {
+		return "read as local time"
+	}
+	return "read as " + f.location().String() + " time"
+}
+
+// Open the file for reading. A failure is remembered so it is reported only once.
+func (f *SourceFile) open() (*os.File, error) {
+	if f.openErr != nil {
+		return nil, f.openErr
+	}
+	file, err := os.Open(f.Path)
+	if err != nil {
+		f.openErr = err
+	}
+	return file, err
+}
+
+// Read the EXIF tags of the file once
+func (f *SourceFile) exifTags(ctx context.Context) (*exifTags, error) {
+	if f.exif != nil {
+		return f.exif, nil
+	}
+	file, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if tags, ok := readRAWTags(file, f.Info.Size()); ok {
+		f.exif = tags
+		return tags, nil
+	}
+
+	tags := &exifTags{}
+	var rawExif []byte
+	if isHEIF(file, f.Info.Size()) {
+		// The EXIF of HEIF images is an item of the container that scanning often misses
+		rawExif, _ = extractHEIFExif(file, f.Info.Size())
+	}
+	if rawExif == nil {
+		// Scanning a large file without EXIF reads all of it
+		rawExif, err = exif.SearchAndExtractExifWithReader(contextReader{ctx, file})
+	}
+	if err == nil {
+		im, err := exifcommon.NewIfdMappingWithStandard()
+		if err == nil {
+			ti := exif.NewTagIndex()
+			_, index, err := exif.Collect(im, ti, rawExif)
+			if err == nil {
+				tags.dateTimeOriginal, _ = findTagInAllIfds(&index, "DateTimeOriginal")
+				tags.offsetTimeOriginal, _ = findTagInAllIfds(&index, "OffsetTimeOriginal")
+				tags.offsetTime, _ = findTagInAllIfds(&index, "OffsetTime")
+				tags.subSecTimeOriginal, _ = findTagInAllIfds(&index, "SubSecTimeOriginal")
+				tags.make, _ = findTagInAllIfds(&index, "Make")
+				tags.model, _ = findTagInAllIfds(&index, "Model")
+				tags.lens, _ = findTagInAllIfds(&index, "LensModel")
+				tags.serial, _ = findTagInAllIfds(&index, "BodySerialNumber")
+				tags.gpsLatitude = findRationalsInAllIfds(&index, "GPSLatitude")
+				tags.gpsLatitudeRef, _ = findTagInAllIfds(&index, "GPSLatitudeRef")
+				tags.gpsLongitude = findRationalsInAllIfds(&index, "GPSLongitude")
+				tags.gpsLongitudeRef, _ = findTagInAllIfds(&index, "GPSLongitudeRef")
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.exif = tags
+	return tags, nil
+}
+
+// Decode the CR3 metadata of the file once
+func (f *SourceFile) cr3Tags(ctx context.Context) (*cr3Tags, error) {
+	if f.cr3 != nil {
+		return f.cr3, nil
+	}
+	file, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tags := &cr3Tags{}
+	md, err := imagemeta.DecodeCR3(contextReadSeeker{contextReader{ctx, file}, file})
+	if err == nil {
+		tags.decoded = true
+		tags.dateTimeOriginal = md.DateTimeOriginal()
+		tags.naive = tags.dateTimeOriginal.Location() == time.UTC
+		tags.gpsLatitude, tags.gpsLongitude = md.GPS.Latitude(), md.GPS.Longitude()
+		tags.make, tags.model, tags.lens = md.Make, md.Model, md.LensModel
+		tags.serial = md.CameraSerial
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.cr3 = tags
+	return tags, nil
+}
+
+// Fill in the camera and lens from EXIF, or from the CR3 or video metadata when EXIF has none
+func (f *SourceFile) camera(ctx context.Context, meta *FileMeta) {
+	if tags, err := f.exifTags(ctx); err == nil && (tags.make != "" || tags.model != "") {
+		meta.Make, meta.Model, meta.Lens, meta.Serial = tags.make, tags.model, tags.lens, tags.serial
+		return
+	}
+	if tags, err := f.cr3Tags(ctx); err == nil && tags.decoded && (tags.make != "" || tags.model != "") {
+		meta.Make, meta.Model, meta.Lens, meta.Serial = tags.make, tags.model, tags.lens, tags.serial
+		return
+	}
+	if f.video != nil {
+		if tags, err := f.videoTags(ctx); err == nil {
+			meta.Make, meta.Model = tags.make, tags.model
+		}
+	}
+}
+
+// exifResolver reads DateTimeOriginal with OffsetTimeOriginal or OffsetTime
+type exifResolver struct{}
+
+func (exifResolver) Name() string { return TimeSourceExif }
+
+func (exifResolver) Resolve(ctx context.Context, f *SourceFile) (Resolution, error) {
+	tags, err := f.exifTags(ctx)
+	if err != nil || tags.dateTimeOriginal == "" {
+		return Resolution{}, err
+	}
+	layout := "2006:01:02 15:04:05"
+	offset, offsetTag := tags.offsetTimeOriginal, "OffsetTimeOriginal"
+	if offset == "" {
+		offset, offsetTag = tags.offsetTime, "OffsetTime"
+	}
+	// Burst shots taken within the same second differ only in SubSecTimeOriginal
+	subSec, _ := parseSubSec(tags.subSecTimeOriginal)
+	// A naive time is wall clock time where the photo was taken, so the GPS position tells
+	// its zone better than --tz
+	loc, readAs, confidence := f.location(), f.readAs(), 0.9
+	if zone := tags.gpsZone(); zone != nil {
+		loc, readAs, confidence = zone, "read as "+zone.String()+" time from the GPS position", 0.95
+	}
+	reason := "EXIF DateTimeOriginal without an offset, " + readAs
+	if offset != "" {
+		// Attempt to parse with timezone offset
+		t, err := time.Parse(layout+"-07:00", tags.dateTimeOriginal+offset)
+		if err == nil {
+			return Resolution{Timestamp: t.Add(subSec), Confidence: 1, Reason: "EXIF DateTimeOriginal with " + offsetTag}, nil
+		}
+		reason = fmt.Sprintf("EXIF DateTimeOriginal with unreadable %s %q, %s", offsetTag, offset, readAs)
+	}
+
+	// Fallback: parse as naive time if no offset or if offset parsing failed
+	t, err := time.ParseInLocation(layout, tags.dateTimeOriginal, loc)
+	if err != nil {
+		return Resolution{}, fmt.Errorf("error parsing DateTimeOriginal: %w", err)
+	}
+	return Resolution{Timestamp: t.Add(subSec), Confidence: confidence, Reason: reason}, nil
+}
+
+// cr3Resolver reads DateTimeOriginal from CR3 files, whose EXIF go-exif cannot find
+type cr3Resolver struct{}
+
+func (cr3Resolver) Name() string { return TimeSourceCR3 }
+
+func (cr3Resolver) Resolve(ctx context.Context, f *SourceFile) (Resolution, error) {
+	tags, err := f.cr3Tags(ctx)
+	if err != nil || tags.dateTimeOriginal.IsZero() {
+		return Resolution{}, err
+	}
+	t, reason, confidence := tags.dateTimeOriginal, "CR3 DateTimeOriginal", 0.9
+	if tags.naive {
+		// Wall clock time like a naive EXIF DateTimeOriginal, read in the same zone
+		loc, readAs := f.location(), f.readAs()
+		if zone := positionZone(tags.gpsLatitude, tags.gpsLongitude); zone != nil {
+			loc, readAs, confidence = zone, "read as "+zone.String()+" time from the GPS position", 0.95
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+		reason = "CR3 DateTimeOriginal without an offset, " + readAs
+	}
+	// imagemeta reads a two-digit SubSecTimeOriginal as milliseconds, so take the fraction
+	// from the CMT2 EXIF block of the Canon uuid box instead
+	if video, err := f.videoTags(ctx); err == nil {
+		if subSec, ok := parseSubSec(video.canonSubSec); ok {
+			t = t.Add(-time.Duration(t.Nanosecond())).Add(subSec)
+		}
+	}
+	return Resolution{Timestamp: t, Confidence: confidence, Reason: reason}, nil
+}
+
+// Parse a SubSecTime value, the decimal digits of the fraction of a second ("45" is 0.45s)
+func parseSubSec(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || len(s) > 9 {
+		return 0, false
+	}
+	var ns int64
+	for i := 0; i < 9; i++ {
+		ns *= 10
+		if i < len(s) {
+			if s[i] < '0' || s[i] > '9' {
+				return 0, false
+			}
+			ns += int64(s[i] - '0')
+		}
+	}
+	return time.Duration(ns), true
+}
+
+// filenameResolver reads a date and time embedded in the file name. Without patterns it uses
+// the built-in ones, NewFilenameResolver adds the user's.
+type filenameResolver struct {
+	patterns []filenamePattern
+}
+
+func (filenameResolver) Name() string { return TimeSourceFilename }
+
+func (r filenameResolver) Resolve(ctx context.Context, f *SourceFile) (Resolution, error) {
+	patterns := r.patterns
+	if patterns == nil {
+		patterns = builtinFilenamePatterns
+	}
+	t, hasTime, p, ok := timestampFromFilename(f.Info.Name(), f.location(), patterns)
+	if !ok {
+		return Resolution{}, nil
+	}
+	res := Resolution{Timestamp: t, Confidence: filenameConfidence, Reason: "date and time in the file name"}
+	if !hasTime {
+		res.Confidence, res.Reason = filenameDateConfidence, "date in the file name, at noon"
+	}
+	if p.user {
+		res.Reason += fmt.Sprintf(" (pattern %s)", p.re)
+	}
+	return res, nil
+}
+
+// How much a modtime is trusted: copies and edits change it
+const modTimeConfidence = 0.2
+
+// modTimeResolver uses the file's modification time, which always exists
+type modTimeResolver struct{}
+
+func (modTimeResolver) Name() string { return TimeSourceModTime }
+
+func (modTimeResolver) Resolve(ctx context.Context, f *SourceFile) (Resolution, error) {
+	return Resolution{Timestamp: f.Info.ModTime(), Confidence: modTimeConfidence, Reason: "file modification time"}, nil
+}
+
+// Built-in resolvers by name, in the order they are listed in help texts
+var builtinResolvers = []TimestampResolver{
+	exifResolver{},
+	cr3Resolver{},
+	videoResolver{},
+	xmpResolver{},
+	filenameResolver{},
+	modTimeResolver{},
+}
+
+// DefaultTimeSources is the resolver chain used when Options.Resolvers is empty
+const DefaultTimeSources = "exif,cr3,video,filename,modtime"
+
+// TimeSourceNames lists the names of the built-in resolvers
+func TimeSourceNames() []string {
+	names := make([]string, len(builtinResolvers))
+	for i, r := range builtinResolvers {
+		names[i] = r.Name()
+	}
+	return names
+}
+
+// ParseTimeSources builds a resolver chain from a comma-separated list of built-in resolver
+// names such as "exif,xmp,filename,modtime". "mtime" is accepted for "modtime".
+func ParseTimeSources(spec string) ([]TimestampResolver, error) {
+	var chain []TimestampResolver
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "mtime" {
+			name = TimeSourceModTime
+		}
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("time source %q listed twice", name)
+		}
+		seen[name] = true
+		found := false
+		for _, r := range builtinResolvers {
+			if r.Name() == name {
+				chain = append(chain, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown time source %q (use %s)", name, strings.Join(TimeSourceNames(), ", "))
+		}
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no time source given")
+	}
+	return chain, nil
+}
+
+// DefaultResolvers returns the resolver chain of DefaultTimeSources
+func DefaultResolvers() []TimestampResolver {
+	chain, _ := ParseTimeSources(DefaultTimeSources)
+	return chain
+}
+
+// TimeOptions choose how files are dated and in which zone their folders and names are
+// rendered. They are shared by Options, ReorganizeOptions and InspectOptions.
+type TimeOptions struct {
+	// Resolvers are tried in order to find the capture time of a file, nil uses
+	// DefaultResolvers
+	Resolvers []TimestampResolver
+	// ClockRules shift the resolved timestamps of cameras whose clock is set wrong
+	ClockRules []ClockRule
+	// TimeZone is the zone of timestamps recorded without an offset, nil means local time
+	TimeZone *time.Location
+	// FolderTimeZone is the zone in which folder and file names are rendered, nil keeps the
+	// zone each timestamp was resolved in
+	FolderTimeZone *time.Location
+}
+
+// The part of the options resolveTimestamp needs
+func (o TimeOptions) timestampConfig() timestampConfig {
+	return timestampConfig{resolvers: o.Resolvers, clockRules: o.ClockRules, location: o.TimeZone}
+}
+
+// timestampConfig is how resolveTimestamp dates files
+type timestampConfig struct {
+	// resolvers is the chain to try, nil uses DefaultResolvers
+	resolvers []TimestampResolver
+	// clockRules correct the timestamps of cameras with a wrong clock
+	clockRules []ClockRule
+	// location is the zone of timestamps without an offset, nil means local time
+	location *time.Location
+}
+
+// Resolve the capture time of a file with the first resolver of the chain that finds one,
+// falling back to its modtime, and apply the clock rule for its camera. The only error
+// returned is that of ctx when it is done before the metadata was read.
+func resolveTimestamp(ctx context.Context, tc timestampConfig, path string, fi os.FileInfo, logf func(string, ...any)) (FileMeta, error) {
+	chain := tc.resolvers
+	if len(chain) == 0 {
+		chain = DefaultResolvers()
+	}
+	f := &SourceFile{Path: path, Info: fi, Location: tc.location}
+	var meta FileMeta
+	var tried []string
+	for _, r := range chain {
+		res, err := r.Resolve(ctx, f)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return FileMeta{}, ctxErr
+		}
+		if err != nil {
+			if err == f.openErr {
+				// Every resolver reading the file fails the same way
+				if len(tried) == 0 || tried[len(tried)-1] != "open" {
+					logf("%s: error opening file: %v", fi.Name(), err)
+				}
+				tried = append(tried, "open")
+			} else {
+				logf("%s: %s: %v", fi.Name(), r.Name(), err)
+			}
+			continue
+		}
+		if res.Timestamp.IsZero() {
+			tried = append(tried, r.Name())
+			continue
+		}
+		meta = FileMeta{Timestamp: res.Timestamp, Source: r.Name(), Confidence: res.Confidence, Reason: res.Reason}
+		break
+	}
+
+	if meta.Timestamp.IsZero() {
+		// No resolver of the chain found anything
+		meta = FileMeta{Timestamp: fi.ModTime(), Source: TimeSourceModTime, Confidence: modTimeConfidence}
+	}
+	if meta.Source == TimeSourceModTime {
+		meta.Reason = f.fallbackReason(tried)
+		logf("%s: %s", fi.Name(), meta.Reason)
+	}
+	if f.openErr == nil {
+		f.camera(ctx, &meta)
+	}
+	if rule, ok := findClockRule(tc.clockRules, meta); ok {
+		meta.Timestamp = meta.Timestamp.Add(rule.Shift)
+		meta.ClockShift = rule.Shift
+		logf("%s: clock shifted by %s for %s", fi.Name(), formatShift(rule.Shift), rule)
+	}
+	return meta, nil
+}
+
+// Explain why the modtime is used after the resolvers in tried found nothing
+func (f *SourceFile) fallbackReason(tried []string) string {
+	switch {
+	case f.exif != nil && f.exif.dateTimeOriginal != "":
+		return "failed to parse EXIF, using ModTime"
+	case f.exif != nil || f.openErr != nil:
+		return "no EXIF data found, using ModTime"
+	case len(tried) > 0:
+		return fmt.Sprintf("no timestamp from %s, using ModTime", strings.Join(tried, ", "))
+	}
+	return "using ModTime"
+}
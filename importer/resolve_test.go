@@ -0,0 +1,422 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedResolver dates every file with the same time
+type fixedResolver struct {
+	t time.Time
+}
+
+func (fixedResolver) Name() string { return "fixed" }
+
+func (r fixedResolver) Resolve(ctx context.Context, f *SourceFile) (Resolution, error) {
+	return Resolution{Timestamp: r.t, Confidence: 0.5, Reason: "fixed for the test"}, nil
+}
+
+func TestParseTimeSources(t *testing.T) {
+	chain, err := ParseTimeSources(" EXIF, filename,mtime ")
+	if err != nil {
+		t.Fatalf("ParseTimeSources returned error: %v", err)
+	}
+	var names []string
+	for _, r := range chain {
+		names = append(names, r.Name())
+	}
+	if got := strings.Join(names, ","); got != "exif,filename,modtime" {
+		t.Fatalf("unexpected chain: %s", got)
+	}
+
+	for spec, want := range map[string]string{
+		"exif,gps":      `unknown time source "gps"`,
+		"exif,exif":     `time source "exif" listed twice`,
+		" , ":           "no time source given",
+		"modtime,mtime": `time source "modtime" listed twice`,
+	} {
+		if _, err := ParseTimeSources(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("ParseTimeSources(%q): expected error containing %q, got: %v", spec, want, err)
+		}
+	}
+}
+
+func TestResolveTimestampFollowsChainOrder(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "IMG_20230102_030405.jpg")
+	tiff := buildTIFF(
+		[]tiffEntry{asciiEntry(0x010f, "Canon"), asciiEntry(0x0110, "Canon EOS R6")},
+		[]tiffEntry{asciiEntry(0x9003, "2024:06:01 14:22:33"), asciiEntry(0x9011, "+02:00")},
+		nil,
+	)
+	mustWriteBytes(t, path, buildJPEG(tiff))
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	logf := func(string, ...any) {}
+
+	chain, _ := ParseTimeSources("exif,filename")
+	meta, err := resolveTimestamp(context.Background(), timestampConfig{resolvers: chain}, path, fi, logf)
+	if err != nil {
+		t.Fatalf("resolveTimestamp returned error: %v", err)
+	}
+	if meta.Source != TimeSourceExif || meta.Confidence != 1 || meta.Timestamp.Format(time.RFC3339) != "2024-06-01T14:22:33+02:00" {
+		t.Fatalf("expected the EXIF time, got: %+v", meta)
+	}
+
+	chain, _ = ParseTimeSources("filename,exif")
+	meta, err = resolveTimestamp(context.Background(), timestampConfig{resolvers: chain}, path, fi, logf)
+	if err != nil {
+		t.Fatalf("resolveTimestamp returned error: %v", err)
+	}
+	want := time.Date(2023, 1, 2, 3, 4, 5, 0, time.Local)
+	if meta.Source != TimeSourceFilename || meta.Confidence != 0.6 || !meta.Timestamp.Equal(want) {
+		t.Fatalf("expected the file name time, got: %+v", meta)
+	}
+	if meta.Make != "Canon" || meta.Model != "Canon EOS R6" {
+		t.Fatalf("expected the camera from EXIF whichever resolver wins, got: %+v", meta)
+	}
+}
+
+func TestResolveTimestampFallsBackToModTime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	mustWriteFile(t, path, "no metadata")
+	mtime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	mustSetMtime(t, path, mtime)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+
+	var logs []string
+	chain, _ := ParseTimeSources("filename,xmp")
+	meta, err := resolveTimestamp(context.Background(), timestampConfig{resolvers: chain}, path, fi, func(format string, args ...any) {
+		logs = append(logs, format)
+	})
+	if err != nil {
+		t.Fatalf("resolveTimestamp returned error: %v", err)
+	}
+	if meta.Source != TimeSourceModTime || !meta.Timestamp.Equal(mtime) || meta.Reason != "no timestamp from filename, xmp, using ModTime" {
+		t.Fatalf("expected the modtime fallback, got: %+v", meta)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("expected the fallback to be logged once, got: %v", logs)
+	}
+}
+
+func TestImportUsesCustomResolver(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	mustWriteFile(t, filepath.Join(from, "a.jpg"), "a")
+
+	im, err := New(Options{From: from, To: to, TimeOptions: TimeOptions{Resolvers: []TimestampResolver{fixedResolver{time.Date(2020, 2, 3, 4, 5, 6, 0, time.Local)}}}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	summary, err := im.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	f := summary.Files[0]
+	if f.Meta.Source != "fixed" || f.Meta.Confidence != 0.5 || f.Destination != filepath.Join(to, "2020-02-03-jpg", "a.jpg") {
+		t.Fatalf("expected the custom resolver to date the file, got: %+v", f)
+	}
+}
+
+func TestXMPResolverReadsSidecarAndEmbeddedPacket(t *testing.T) {
+	dir := t.TempDir()
+	sidecarPhoto := filepath.Join(dir, "DSC_0001.NEF")
+	mustWriteFile(t, sidecarPhoto, "raw data")
+	mustWriteFile(t, filepath.Join(dir, "DSC_0001.xmp"), `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description
+		xmp:CreateDate="2021-01-01T00:00:00"
+		exif:DateTimeOriginal="2024-06-01T14:22:33.50+02:00"/></rdf:RDF></x:xmpmeta>`)
+	embedded := filepath.Join(dir, "scan.jpg")
+	mustWriteFile(t, embedded, "\xff\xd8 binary <x:xmpmeta><rdf:Description><photoshop:DateCreated>2019-07-08T09:10</photoshop:DateCreated></rdf:Description></x:xmpmeta>")
+	plain := filepath.Join(dir, "plain.jpg")
+	mustWriteFile(t, plain, "nothing here")
+
+	resolve := func(path string) Resolution {
+		t.Helper()
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat failed: %v", err)
+		}
+		res, err := xmpResolver{}.Resolve(context.Background(), &SourceFile{Path: path, Info: fi})
+		if err != nil {
+			t.Fatalf("Resolve returned error: %v", err)
+		}
+		return res
+	}
+
+	res := resolve(sidecarPhoto)
+	if res.Timestamp.Format(time.RFC3339Nano) != "2024-06-01T14:22:33.5+02:00" || res.Confidence != 0.95 ||
+		res.Reason != "XMP exif:DateTimeOriginal in sidecar DSC_0001.xmp" {
+		t.Fatalf("unexpected sidecar resolution: %+v", res)
+	}
+	res = resolve(embedded)
+	if !res.Timestamp.Equal(time.Date(2019, 7, 8, 9, 10, 0, 0, time.Local)) || res.Confidence != 0.8 ||
+		res.Reason != "embedded XMP photoshop:DateCreated, read as local time" {
+		t.Fatalf("unexpected embedded resolution: %+v", res)
+	}
+	if res := resolve(plain); !res.Timestamp.IsZero() {
+		t.Fatalf("expected no timestamp without XMP, got: %+v", res)
+	}
+}
+
+func TestParseSubSec(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"45":        450 * time.Millisecond,
+		"045":       45 * time.Millisecond,
+		"5":         500 * time.Millisecond,
+		"123456789": 123456789,
+		" 12 ":      120 * time.Millisecond,
+	} {
+		if got, ok := parseSubSec(value); !ok || got != want {
+			t.Fatalf("parseSubSec(%q) = %v, %v; want %v", value, got, ok, want)
+		}
+	}
+	for _, value := range []string{"", "1a", "1234567890"} {
+		if _, ok := parseSubSec(value); ok {
+			t.Fatalf("parseSubSec(%q): expected failure", value)
+		}
+	}
+}
+
+func TestImportCarriesSubSecondsToRenameAndMtime(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	// Two burst shots within the same second
+	for name, subSec := range map[string]string{"IMG_0001.JPG": "05", "IMG_0002.JPG": "55"} {
+		tiff := buildTIFF(nil, []tiffEntry{
+			asciiEntry(0x9003, "2024:06:01 14:22:33"),
+			asciiEntry(0x9011, "+02:00"),
+			asciiEntry(0x9291, subSec),
+		}, nil)
+		mustWriteBytes(t, filepath.Join(from, name), buildJPEG(tiff))
+	}
+
+	im, err := New(Options{From: from, To: to, Layout: "{date}", Rename: "{HHmmss}{SSS}", Mtime: MtimeCapture})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if _, err := im.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	for name, want := range map[string]time.Time{
+		"142233050.JPG": time.Date(2024, 6, 1, 12, 22, 33, 50_000_000, time.UTC),
+		"142233550.JPG": time.Date(2024, 6, 1, 12, 22, 33, 550_000_000, time.UTC),
+	} {
+		fi, err := os.Stat(filepath.Join(to, "2024-06-01", name))
+		if err != nil {
+			t.Fatalf("expected %s in the destination: %v", name, err)
+		}
+		if !fi.ModTime().Equal(want) {
+			t.Fatalf("%s: expected mtime %v, got %v", name, want, fi.ModTime())
+		}
+	}
+}
+
+func TestImportReadsNaiveTimesInTimeZoneAndFilesByFolderTimeZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	for name, entries := range map[string][]tiffEntry{
+		// 2024-06-01 16:30 UTC once read as Tokyo time
+		"naive.jpg": {asciiEntry(0x9003, "2024:06:02 01:30:00")},
+		// 2024-06-02 03:00 in Tokyo
+		"zoned.jpg": {asciiEntry(0x9003, "2024:06:01 20:00:00"), asciiEntry(0x9011, "+02:00")},
+		// 2024-06-01 17:00 in Tokyo, before the window
+		"early.jpg": {asciiEntry(0x9003, "2024:06:01 10:00:00"), asciiEntry(0x9011, "+02:00")},
+	} {
+		mustWriteBytes(t, filepath.Join(from, name), buildJPEG(buildTIFF(nil, entries, nil)))
+	}
+
+	to := filepath.Join(root, "to")
+	start := time.Date(2024, 6, 2, 0, 0, 0, 0, tokyo)
+	im, err := New(Options{From: from, To: to, Layout: "{date}", TimeOptions: TimeOptions{TimeZone: tokyo, FolderTimeZone: tokyo},
+		Start: start, End: start.AddDate(0, 0, 1).Add(-time.Nanosecond)})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if _, err := im.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "2024-06-01", "early.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("expected early.jpg outside the window to be skipped, got: %v", err)
+	}
+	for _, name := range []string{"naive.jpg", "zoned.jpg"} {
+		if _, err := os.Stat(filepath.Join(to, "2024-06-02", name)); err != nil {
+			t.Fatalf("expected %s in the Tokyo date folder: %v", name, err)
+		}
+	}
+
+	// Without --folder-tz the naive photo keeps its Tokyo date, with another zone it moves
+	other := filepath.Join(root, "utc")
+	im, err = New(Options{From: from, To: other, Layout: "{date}", TimeOptions: TimeOptions{TimeZone: tokyo, FolderTimeZone: time.UTC}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if _, err := im.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(other, "2024-06-01", "naive.jpg")); err != nil {
+		t.Fatalf("expected naive.jpg in the UTC date folder: %v", err)
+	}
+}
+
+// buildCR3 wraps the Exif and GPS TIFF blocks in a minimal CR3 file, as the CMT2 and CMT4
+// boxes of the Canon uuid box in moov
+func buildCR3(cmt2, cmt4 []byte) []byte {
+	cmt1 := buildTIFF([]tiffEntry{asciiEntry(0x010f, "Canon"), asciiEntry(0x0110, "Canon EOS R6")}, nil, nil)
+	uuid := isoBoxBytes("uuid", canonUUID, isoBoxBytes("CNCV", []byte("CanonCR3_001/00.09.00/00.00.00")),
+		isoBoxBytes("CMT1", cmt1), isoBoxBytes("CMT2", cmt2), isoBoxBytes("CMT3", buildTIFF(nil, nil, nil)), isoBoxBytes("CMT4", cmt4))
+	return bytes.Join([][]byte{
+		isoBoxBytes("ftyp", []byte("crx "), []byte{0, 0, 0, 1}, []byte("crx isom")),
+		isoBoxBytes("moov", uuid),
+		isoBoxBytes("free"),
+		isoBoxBytes("mdat", []byte("frames")),
+	}, nil)
+}
+
+func TestImportReadsNaiveCR3TimesInTimeZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	// 2024-06-01 16:30 UTC once read as Tokyo time
+	dto := buildTIFF([]tiffEntry{asciiEntry(0x9003, "2024:06:02 01:30:00")}, nil, nil)
+	mustWriteBytes(t, filepath.Join(from, "IMG_0001.CR3"), buildCR3(dto, buildTIFF(nil, nil, nil)))
+
+	to := filepath.Join(root, "to")
+	im, err := New(Options{From: from, To: to, Layout: "{date}", TimeOptions: TimeOptions{TimeZone: tokyo, FolderTimeZone: time.UTC}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	summary, err := im.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if summary.Copied != 1 || len(summary.Files) != 1 {
+		t.Fatalf("expected one copied file, got: %+v", summary)
+	}
+	meta := summary.Files[0].Meta
+	if meta.Source != TimeSourceCR3 || !meta.Timestamp.Equal(time.Date(2024, 6, 1, 16, 30, 0, 0, time.UTC)) ||
+		meta.Confidence != 0.9 || meta.Reason != "CR3 DateTimeOriginal without an offset, read as Asia/Tokyo time" {
+		t.Fatalf("expected the CR3 time read as Tokyo time, got: %+v", meta)
+	}
+	if _, err := os.Stat(filepath.Join(to, "2024-06-01", "IMG_0001.CR3")); err != nil {
+		t.Fatalf("expected IMG_0001.CR3 in the UTC date folder: %v", err)
+	}
+
+	// A GPS position names the zone instead, here Paris
+	gps := buildTIFF([]tiffEntry{
+		asciiEntry(0x0001, "N"), rationalEntry(0x0002, [2]uint32{48, 1}, [2]uint32{51, 1}, [2]uint32{0, 1}),
+		asciiEntry(0x0003, "E"), rationalEntry(0x0004, [2]uint32{2, 1}, [2]uint32{21, 1}, [2]uint32{0, 1}),
+	}, nil, nil)
+	path := filepath.Join(root, "IMG_0002.CR3")
+	mustWriteBytes(t, path, buildCR3(dto, gps))
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	res, err := cr3Resolver{}.Resolve(context.Background(), &SourceFile{Path: path, Info: fi, Location: tokyo})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if !res.Timestamp.Equal(time.Date(2024, 6, 1, 23, 30, 0, 0, time.UTC)) || res.Confidence != 0.95 ||
+		res.Reason != "CR3 DateTimeOriginal without an offset, read as Europe/Paris time from the GPS position" {
+		t.Fatalf("expected the CR3 time read in the GPS zone, got: %+v", res)
+	}
+}
+
+func TestFilenameResolverReadsBuiltinAndUserPatterns(t *testing.T) {
+	resolve := func(r TimestampResolver, name string) Resolution {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), name)
+		mustWriteFile(t, path, "no metadata")
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat failed: %v", err)
+		}
+		res, err := r.Resolve(context.Background(), &SourceFile{Path: path, Info: fi, Location: time.UTC})
+		if err != nil {
+			t.Fatalf("Resolve returned error: %v", err)
+		}
+		return res
+	}
+
+	for name, want := range map[string]string{
+		"IMG_20240601_142233.jpg":                       "2024-06-01T14:22:33Z",
+		"PXL_20240601_142233123.MP.jpg":                 "2024-06-01T14:22:33.123Z",
+		"2024-06-01 14.22.33.png":                       "2024-06-01T14:22:33Z",
+		"Screenshot 2024-06-01 at 14.22.33.png":         "2024-06-01T14:22:33Z",
+		"Screen Shot 2020-06-01 at 2.22.33 PM.png":      "2020-06-01T14:22:33Z",
+		"Screen Shot 2020-06-01 at 12.05.00 AM.png":     "2020-06-01T00:05:00Z",
+		"Screen Recording 2024-06-01 at 9.05.07 AM.mov": "2024-06-01T09:05:07Z",
+	} {
+		res := resolve(filenameResolver{}, name)
+		if res.Timestamp.Format(time.RFC3339Nano) != want || res.Confidence != 0.6 || res.Reason != "date and time in the file name" {
+			t.Fatalf("%s: expected %s, got %+v", name, want, res)
+		}
+	}
+	for name, want := range map[string]string{
+		"VID-20240601-WA0003.mp4": "2024-06-01T12:00:00Z",
+		"scan-2019-07-08.jpg":     "2019-07-08T12:00:00Z",
+	} {
+		res := resolve(filenameResolver{}, name)
+		if res.Timestamp.Format(time.RFC3339Nano) != want || res.Confidence != 0.4 || res.Reason != "date in the file name, at noon" {
+			t.Fatalf("%s: expected %s, got %+v", name, want, res)
+		}
+	}
+	for _, name := range []string{"IMG_0001.jpg", "IMG_20240230_142233.jpg", "IMG_20241301_000000.jpg", "a1b2c3d4e5f60718293.jpg"} {
+		if res := resolve(filenameResolver{}, name); !res.Timestamp.IsZero() {
+			t.Fatalf("%s: expected no timestamp, got %+v", name, res)
+		}
+	}
+
+	r, err := NewFilenameResolver(`^DJI_(?P<day>\d{2})(?P<month>\d{2})(?P<year>\d{4})`)
+	if err != nil {
+		t.Fatalf("NewFilenameResolver returned error: %v", err)
+	}
+	res := resolve(r, "DJI_01062024_0042.mp4")
+	if res.Timestamp.Format(time.RFC3339) != "2024-06-01T12:00:00Z" || res.Confidence != 0.4 ||
+		!strings.HasSuffix(res.Reason, "(pattern ^DJI_(?P<day>\\d{2})(?P<month>\\d{2})(?P<year>\\d{4}))") {
+		t.Fatalf("unexpected resolution from a user pattern: %+v", res)
+	}
+	// The built-in patterns still apply after the user's
+	if res := resolve(r, "IMG_20240601_142233.jpg"); res.Timestamp.Format(time.RFC3339) != "2024-06-01T14:22:33Z" {
+		t.Fatalf("expected the built-in pattern to match, got %+v", res)
+	}
+
+	for _, expr := range []string{`(?P<year>\d{4})(?P<month>\d{2})`, `(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})(?P<minute>\d{2})`, `(`} {
+		if _, err := NewFilenameResolver(expr); err == nil {
+			t.Fatalf("expected %q to be rejected", expr)
+		}
+	}
+}
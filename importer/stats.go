@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StatsOptions select the library Stats summarizes
+type StatsOptions struct {
+	To string
+}
+
+// FileCount is the number and total size of a group of library files
+type FileCount struct {
+	Files int
+	Bytes int64
+}
+
+// LibraryStats summarizes the files of a library and the runs that imported into it
+type LibraryStats struct {
+	Total  FileCount
+	ByExt  map[string]*FileCount
+	ByYear map[string]*FileCount
+	Runs   int
+	Undone int
+}
+
+// Count the files of a library by extension and by year of their mtime (which the importer
+// sets to the source file's mtime) and list how many runs imported into it
+func Stats(cfg StatsOptions, out io.Writer) (LibraryStats, error) {
+	summary := LibraryStats{ByExt: make(map[string]*FileCount), ByYear: make(map[string]*FileCount)}
+	jobs, err := listLibraryFiles(cfg.To)
+	if err != nil {
+		return summary, fmt.Errorf("list %s failed: %w", cfg.To, err)
+	}
+	for _, job := range jobs {
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(job.rel)), ".")
+		if ext == "" {
+			ext = "(none)"
+		}
+		year := strconv.Itoa(job.info.ModTime().Year())
+		for _, c := range []*FileCount{&summary.Total, countFor(summary.ByExt, ext), countFor(summary.ByYear, year)} {
+			c.Files++
+			c.Bytes += job.info.Size()
+		}
+	}
+	manifests, err := listRunManifests(cfg.To)
+	if err != nil {
+		return summary, fmt.Errorf("list runs failed: %w", err)
+	}
+	for _, m := range manifests {
+		summary.Runs++
+		if m.Undone != nil {
+			summary.Undone++
+		}
+	}
+
+	fmt.Fprintf(out, "Library %s\n", cfg.To)
+	fmt.Fprintf(out, "  files: %d (%s)\n", summary.Total.Files, formatBytes(summary.Total.Bytes))
+	fmt.Fprintf(out, "  runs:  %d (%d undone)\n", summary.Runs, summary.Undone)
+	printCounts(out, "By extension", summary.ByExt)
+	printCounts(out, "By year", summary.ByYear)
+	return summary, nil
+}
+
+func countFor(counts map[string]*FileCount, key string) *FileCount {
+	c, ok := counts[key]
+	if !ok {
+		c = &FileCount{}
+		counts[key] = c
+	}
+	return c
+}
+
+func printCounts(out io.Writer, title string, counts map[string]*FileCount) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(out, "%s:\n", title)
+	for _, k := range keys {
+		fmt.Fprintf(out, "  %-8s %6d  %s\n", k, counts[k].Files, formatBytes(counts[k].Bytes))
+	}
+}
+
+// Format a byte count with a binary unit, e.g. 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,171 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UndoOptions select the run Undo reverts
+type UndoOptions struct {
+	To    string
+	RunID string
+}
+
+// UndoSummary counts what Undo did with the files of a run
+type UndoSummary struct {
+	Removed         int
+	Kept            int
+	Missing         int
+	Restored        int
+	SourcesRestored int
+	DirsRemoved     int
+}
+
+// Undone files are no longer finished, so --resume imports them again
+const OutcomeUndone = "undone"
+
+// Revert a run from its manifest: remove the files it created if they are unchanged, put moved
+// sources and backed up files back, and delete the folders it created once they are empty
+func Undo(cfg UndoOptions, out io.Writer) (UndoSummary, error) {
+	var summary UndoSummary
+	m, err := loadRunManifest(cfg.To, cfg.RunID)
+	if err != nil {
+		return summary, err
+	}
+	if m.Undone != nil {
+		return summary, fmt.Errorf("run %s was already undone at %s", m.ID, m.Undone.Format(time.RFC3339))
+	}
+	journal, err := openJournal(cfg.To, false, false)
+	if err != nil {
+		return summary, fmt.Errorf("open import journal failed: %w", err)
+	}
+	defer journal.Close()
+
+	// Remember the files undone so far even when a later one fails
+	saved := false
+	defer func() {
+		if !saved {
+			m.save()
+		}
+	}()
+
+	fmt.Fprintf(out, "Undoing run %s in %s\n", m.ID, cfg.To)
+	for i, f := range m.Files {
+		if f.Undone {
+			continue
+		}
+		path := filepath.Join(cfg.To, filepath.FromSlash(f.Path))
+		sum, err := hashFile(context.Background(), path)
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(out, "%s: already gone\n", f.Path)
+			summary.Missing++
+			continue
+		}
+		if err != nil {
+			return summary, fmt.Errorf("%s: hash failed: %w", f.Path, err)
+		}
+		if sum != f.SHA256 {
+			fmt.Fprintf(out, "%s: changed since import, keeping\n", f.Path)
+			summary.Kept++
+			continue
+		}
+
+		if f.Moved {
+			if _, err := os.Stat(f.Source); os.IsNotExist(err) {
+				if err := os.MkdirAll(filepath.Dir(f.Source), 0o755); err != nil {
+					return summary, fmt.Errorf("%s: recreate source folder failed: %w", f.Path, err)
+				}
+				if err := copyFileWith(context.Background(), path, f.Source, copyOptions{verify: DefaultHashAlgorithm}); err != nil {
+					return summary, fmt.Errorf("%s: restore source %s failed: %w", f.Path, f.Source, err)
+				}
+				fmt.Fprintf(out, "%s: restored source %s\n", f.Path, f.Source)
+				summary.SourcesRestored++
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return summary, fmt.Errorf("%s: remove failed: %w", f.Path, err)
+		}
+		fmt.Fprintf(out, "%s: removed\n", f.Path)
+		summary.Removed++
+		m.Files[i].Undone = true
+		if err := journal.append(journalEntry{Source: f.Source, Outcome: OutcomeUndone, Destination: path, Finished: time.Now()}); err != nil {
+			fmt.Fprintf(out, "%s: update import journal failed: %v\n", f.Path, err)
+		}
+
+		if f.Backup != "" {
+			backup := filepath.Join(cfg.To, filepath.FromSlash(f.Backup))
+			if err := os.Rename(backup, path); err != nil {
+				return summary, fmt.Errorf("%s: restore overwritten file failed: %w", f.Path, err)
+			}
+			fmt.Fprintf(out, "%s: restored overwritten file\n", f.Path)
+			summary.Restored++
+		} else if f.Overwrote {
+			fmt.Fprintf(out, "%s: replaced an existing file that was not backed up\n", f.Path)
+		}
+	}
+
+	dirs := make([]string, 0, len(m.CreatedDirs)+1)
+	for _, dir := range m.CreatedDirs {
+		dirs = append(dirs, filepath.Join(cfg.To, filepath.FromSlash(dir)))
+	}
+	// The run also created the folders of its backups
+	backups := filepath.Join(cfg.To, metaDirName, backupsDirName, m.ID)
+	for _, f := range m.Files {
+		if f.Backup == "" {
+			continue
+		}
+		dir := filepath.Dir(filepath.Join(cfg.To, filepath.FromSlash(f.Backup)))
+		for ; strings.HasPrefix(dir, backups+string(filepath.Separator)); dir = filepath.Dir(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	dirs = append(dirs, backups)
+	summary.DirsRemoved = removeEmptyDirs(dirs)
+
+	// Files changed since the import stay in the run so undo can retry them once dealt with
+	if summary.Kept == 0 {
+		now := time.Now()
+		m.Undone = &now
+	}
+	saved = true
+	if err := m.save(); err != nil {
+		return summary, fmt.Errorf("update manifest failed: %w", err)
+	}
+
+	fmt.Fprintf(
+		out,
+		"Undone. removed=%d kept=%d missing=%d restored=%d sources_restored=%d folders_removed=%d\n",
+		summary.Removed,
+		summary.Kept,
+		summary.Missing,
+		summary.Restored,
+		summary.SourcesRestored,
+		summary.DirsRemoved,
+	)
+	if summary.Kept > 0 {
+		return summary, fmt.Errorf("%d files changed since the import were kept, run undo again to retry them", summary.Kept)
+	}
+	return summary, nil
+}
+
+// Remove the folders of dirs that are empty and return how many were removed. Deepest folders go first so parents become empty in turn.
+func removeEmptyDirs(dirs []string) int {
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+	removed := 0
+	for _, dir := range dirs {
+		// Folders that are not empty, including ones holding folders made since, stay
+		if os.Remove(dir) == nil {
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Return the id of the only run recorded below root
+func onlyRunID(t *testing.T, root string) string {
+	t.Helper()
+
+	entries, err := os.ReadDir(filepath.Join(root, metaDirName, runsDirName))
+	if err != nil {
+		t.Fatalf("read runs failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one run manifest, got %d", len(entries))
+	}
+	return strings.TrimSuffix(entries[0].Name(), ".json")
+}
+
+func TestRunUndoRevertsImport(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	existingFolder := filepath.Join(to, "2024-07-08-jpg")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	if err := os.MkdirAll(existingFolder, 0o755); err != nil {
+		t.Fatalf("mkdir folder failed: %v", err)
+	}
+	mustWriteFile(t, filepath.Join(existingFolder, "overwritten.jpg"), "original library file")
+
+	for name, mtime := range map[string]time.Time{
+		"overwritten.jpg": time.Date(2024, 7, 8, 9, 0, 0, 0, time.UTC),
+		"changed.jpg":     time.Date(2024, 7, 8, 10, 0, 0, 0, time.UTC),
+		"new.jpg":         time.Date(2024, 7, 9, 10, 0, 0, 0, time.UTC),
+	} {
+		path := filepath.Join(from, name)
+		mustWriteFile(t, path, "imported "+name)
+		mustSetMtime(t, path, mtime)
+	}
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 2,
+		UseModTime: true,
+		OnConflict: conflictOverwrite,
+		KeepBackup: true,
+	}
+	var out bytes.Buffer
+	if _, err := runImport(context.Background(), cfg, &out, nil); err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	mustWriteFile(t, filepath.Join(existingFolder, "changed.jpg"), "edited after import")
+
+	out.Reset()
+	summary, err := Undo(UndoOptions{To: to, RunID: onlyRunID(t, to)}, &out)
+	if err == nil || !strings.Contains(err.Error(), "changed since the import") {
+		t.Fatalf("expected error about the kept file, got: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Removed != 2 || summary.Kept != 1 || summary.Restored != 1 || summary.DirsRemoved < 1 {
+		t.Fatalf("unexpected undo summary: %+v\noutput:\n%s", summary, out.String())
+	}
+	if got := readFileString(t, filepath.Join(existingFolder, "overwritten.jpg")); got != "original library file" {
+		t.Fatalf("expected overwritten file to be restored, got: %q", got)
+	}
+	if got := readFileString(t, filepath.Join(existingFolder, "changed.jpg")); got != "edited after import" {
+		t.Fatalf("expected changed file to be kept, got: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(to, "2024-07-09-jpg")); !os.IsNotExist(err) {
+		t.Fatalf("expected created folder to be removed, stat err=%v", err)
+	}
+
+	// Once the edit is reverted a second undo removes the kept file and finishes the run
+	mustWriteFile(t, filepath.Join(existingFolder, "changed.jpg"), "imported changed.jpg")
+	out.Reset()
+	summary, err = Undo(UndoOptions{To: to, RunID: onlyRunID(t, to)}, &out)
+	if err != nil {
+		t.Fatalf("retried undo returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Removed != 1 || summary.Kept != 0 || summary.Missing != 0 || summary.Restored != 0 {
+		t.Fatalf("unexpected retried undo summary: %+v\noutput:\n%s", summary, out.String())
+	}
+	if got := readFileString(t, filepath.Join(existingFolder, "overwritten.jpg")); got != "original library file" {
+		t.Fatalf("expected restored file to stay after the retry, got: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(existingFolder, "changed.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("expected kept file to be removed by the retry, stat err=%v", err)
+	}
+
+	if _, err := Undo(UndoOptions{To: to, RunID: onlyRunID(t, to)}, &out); err == nil || !strings.Contains(err.Error(), "already undone") {
+		t.Fatalf("expected third undo to be rejected, got: %v", err)
+	}
+}
+
+func TestRunUndoRevertsKilledImport(t *testing.T) {
+	to := t.TempDir()
+	manifest := newRunManifest(Options{From: "/card", To: to}, newRunID(time.Now()))
+	if err := manifest.start(); err != nil {
+		t.Fatalf("start returned error: %v", err)
+	}
+	folder := filepath.Join(to, "2024-07-08-jpg")
+	if err := os.Mkdir(folder, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	path := filepath.Join(folder, "a.jpg")
+	mustWriteFile(t, path, "a")
+	sum, err := hashFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("hashFile returned error: %v", err)
+	}
+	result := FileResult{Source: "/card/a.jpg", Destination: path, SHA256: sum, written: true, createdDirs: []string{folder}}
+	if err := manifest.add(result); err != nil {
+		t.Fatalf("add returned error: %v", err)
+	}
+	// The run is killed before it saves the manifest
+	manifest.close()
+
+	var out bytes.Buffer
+	summary, err := Undo(UndoOptions{To: to, RunID: manifest.ID}, &out)
+	if err != nil {
+		t.Fatalf("Undo returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.Removed != 1 || summary.DirsRemoved < 1 {
+		t.Fatalf("expected the logged file and folder to be removed, got: %+v\noutput:\n%s", summary, out.String())
+	}
+	if _, err := os.Stat(folder); !os.IsNotExist(err) {
+		t.Fatalf("expected created folder to be removed, stat err=%v", err)
+	}
+	if _, err := os.Stat(manifestLogPath(to, manifest.ID)); !os.IsNotExist(err) {
+		t.Fatalf("expected the manifest log to be folded into the manifest, stat err=%v", err)
+	}
+}
+
+func TestRunUndoKeepsFoldersMadeAfterTheImport(t *testing.T) {
+	to := t.TempDir()
+	manifest := newRunManifest(Options{From: "/card", To: to}, newRunID(time.Now()))
+	folder := filepath.Join(to, "2024-07-08-jpg")
+	if err := os.Mkdir(folder, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	manifest.add(FileResult{createdDirs: []string{folder}})
+	if err := manifest.save(); err != nil {
+		t.Fatalf("save returned error: %v", err)
+	}
+	// An empty folder the user made inside the one the run created
+	if err := os.Mkdir(filepath.Join(folder, "picks"), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	summary, err := Undo(UndoOptions{To: to, RunID: manifest.ID}, &out)
+	if err != nil {
+		t.Fatalf("Undo returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if _, err := os.Stat(filepath.Join(folder, "picks")); err != nil || summary.DirsRemoved != 0 {
+		t.Fatalf("expected the user's folder to stay, got %+v, stat err=%v", summary, err)
+	}
+}
+
+func TestRunUndoRestoresMovedSources(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	to := filepath.Join(root, "to")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	src := filepath.Join(from, "moved.jpg")
+	mtime := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+	mustWriteFile(t, src, "moved content")
+	mustSetMtime(t, src, mtime)
+
+	cfg := Options{
+		From:       from,
+		To:         to,
+		End:        time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers: 1,
+		UseModTime: true,
+		Move:       true,
+	}
+	var out bytes.Buffer
+	if _, err := runImport(context.Background(), cfg, &out, nil); err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+
+	summary, err := Undo(UndoOptions{To: to, RunID: onlyRunID(t, to)}, &out)
+	if err != nil {
+		t.Fatalf("Undo returned error: %v\noutput:\n%s", err, out.String())
+	}
+	if summary.SourcesRestored != 1 || summary.Removed != 1 {
+		t.Fatalf("unexpected undo summary: %+v", summary)
+	}
+	if got := readFileString(t, src); got != "moved content" {
+		t.Fatalf("expected source to be restored, got: %q", got)
+	}
+	assertMtimeClose(t, src, mtime, time.Second)
+}
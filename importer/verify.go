@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// VerifyOptions select the runs Verify checks. Without RunIDs every run that was not undone
+// is checked. MaxWorkers below 1 uses DefaultWorkers.
+type VerifyOptions struct {
+	To         string
+	RunIDs     []string
+	MaxWorkers int
+}
+
+// VerifySummary counts the files Verify checked
+type VerifySummary struct {
+	Checked int
+	OK      int
+	Changed int
+	Missing int
+}
+
+// Re-hash the files recorded in the run manifests and compare them with the SHA-256 taken at
+// import time. Without run ids every run that was not undone is checked. When several runs
+// wrote the same path only the latest one is expected to match.
+func Verify(cfg VerifyOptions, out io.Writer) (VerifySummary, error) {
+	var summary VerifySummary
+	if cfg.MaxWorkers < 1 {
+		cfg.MaxWorkers = DefaultWorkers
+	}
+	var manifests []*runManifest
+	if len(cfg.RunIDs) == 0 {
+		all, err := listRunManifests(cfg.To)
+		if err != nil {
+			return summary, fmt.Errorf("list runs failed: %w", err)
+		}
+		for _, m := range all {
+			if m.Undone == nil {
+				manifests = append(manifests, m)
+			}
+		}
+	} else {
+		for _, id := range cfg.RunIDs {
+			m, err := loadRunManifest(cfg.To, id)
+			if err != nil {
+				return summary, err
+			}
+			if m.Undone != nil {
+				return summary, fmt.Errorf("run %s was undone", id)
+			}
+			manifests = append(manifests, m)
+		}
+		sort.Slice(manifests, func(i, j int) bool { return manifests[i].ID < manifests[j].ID })
+	}
+
+	expected := make(map[string]string)
+	for _, m := range manifests {
+		for _, f := range m.Files {
+			if !f.Undone {
+				expected[f.Path] = f.SHA256
+			}
+		}
+	}
+	paths := make([]string, 0, len(expected))
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	results := make([]string, len(paths))
+	var wg sync.WaitGroup
+	indexes := make(chan int)
+	for range cfg.MaxWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				sum, err := hashFile(context.Background(), filepath.Join(cfg.To, filepath.FromSlash(paths[i])))
+				switch {
+				case errors.Is(err, os.ErrNotExist):
+					results[i] = "missing"
+				case err != nil:
+					results[i] = fmt.Sprintf("unreadable: %v", err)
+				case sum != expected[paths[i]]:
+					results[i] = "changed"
+				}
+			}
+		}()
+	}
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, path := range paths {
+		summary.Checked++
+		switch {
+		case results[i] == "":
+			summary.OK++
+			continue
+		case results[i] == "missing":
+			summary.Missing++
+		default:
+			summary.Changed++
+		}
+		fmt.Fprintf(out, "%s: %s\n", path, results[i])
+	}
+
+	fmt.Fprintf(
+		out,
+		"Verified %d run(s). checked=%d ok=%d changed=%d missing=%d\n",
+		len(manifests),
+		summary.Checked,
+		summary.OK,
+		summary.Changed,
+		summary.Missing,
+	)
+	if bad := summary.Changed + summary.Missing; bad > 0 {
+		return summary, fmt.Errorf("%d files are missing or changed", bad)
+	}
+	return summary, nil
+}
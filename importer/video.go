@@ -0,0 +1,356 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Seconds between the QuickTime epoch (1904-01-01 UTC) and the Unix epoch
+const quickTimeEpochOffset = 2082844800
+
+// Largest CTMD sample read while looking for the Canon timestamp
+const maxCTMDSampleSize = 64 << 10
+
+// UUID of the box Canon cameras put their CNTH thumbnail and CMT1-CMT4 metadata in
+var canonUUID = []byte{0x85, 0xc0, 0xb6, 0x87, 0x82, 0x0f, 0x11, 0xe0, 0x81, 0x11, 0xf4, 0xce, 0x46, 0x2b, 0x6a, 0x48}
+
+// Types of the boxes a QuickTime or MP4 file starts with
+var videoFirstBoxes = map[string]bool{"ftyp": true, "moov": true, "wide": true, "free": true, "skip": true, "mdat": true}
+
+// videoTags are the creation times found in a QuickTime or MP4 file, empty for other files
+type videoTags struct {
+	// appleCreationDate is com.apple.quicktime.creationdate, an ISO 8601 time with offset
+	appleCreationDate string
+	// canonDateTimeOriginal, canonOffset and canonSubSec come from the EXIF block in the
+	// Canon CMT2 box
+	canonDateTimeOriginal string
+	canonOffset           string
+	canonSubSec           string
+	// canonCTMD is the first timestamp record of Canon's timed metadata track. It has no
+	// zone, the wall clock time is kept in UTC.
+	canonCTMD time.Time
+	// created is the creation_time of mvhd, or of the first tkhd when mvhd has none
+	created    time.Time
+	createdBox string
+	make       string
+	model      string
+}
+
+// Read the creation times of a QuickTime or MP4 file once
+func (f *SourceFile) videoTags(ctx context.Context) (*videoTags, error) {
+	if f.video != nil {
+		return f.video, nil
+	}
+	file, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tags := readVideoTags(file, f.Info.Size())
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.video = tags
+	return tags, nil
+}
+
+// Collect the creation times from the moov box. Files that are not QuickTime or MP4, and
+// boxes that cannot be parsed, yield no tags.
+func readVideoTags(r io.ReaderAt, size int64) *videoTags {
+	tags := &videoTags{}
+	first, err := readISOBox(r, 0, size)
+	if err != nil || !videoFirstBoxes[first.typ] {
+		return tags
+	}
+	moov, err := findISOBox(r, 0, size, "moov")
+	if err != nil {
+		return tags
+	}
+
+	if mvhd, err := findISOBox(r, moov.start, moov.end, "mvhd"); err == nil {
+		if t, ok := readCreationTime(r, mvhd); ok {
+			tags.created, tags.createdBox = t, "mvhd"
+		}
+	}
+	for off := moov.start; off+8 <= moov.end; {
+		b, err := readISOBox(r, off, moov.end)
+		if err != nil {
+			break
+		}
+		off = b.end
+		switch b.typ {
+		case "trak":
+			if tags.created.IsZero() {
+				if tkhd, err := findISOBox(r, b.start, b.end, "tkhd"); err == nil {
+					if t, ok := readCreationTime(r, tkhd); ok {
+						tags.created, tags.createdBox = t, "tkhd"
+					}
+				}
+			}
+			if tags.canonCTMD.IsZero() {
+				tags.canonCTMD = readCTMDTimestamp(r, b)
+			}
+		case "meta":
+			readAppleKeys(r, b, tags)
+		case "udta":
+			if meta, err := findISOBox(r, b.start, b.end, "meta"); err == nil {
+				readAppleKeys(r, meta, tags)
+			}
+		case "uuid":
+			readCanonUUID(r, b, tags)
+		}
+	}
+	return tags
+}
+
+// Read creation_time from a mvhd or tkhd box. Zero means the encoder did not set it.
+func readCreationTime(r io.ReaderAt, b isoBox) (time.Time, bool) {
+	payload, err := readISOPayload(r, isoBox{typ: b.typ, start: b.start, end: min(b.start+12, b.end)}, 12)
+	if err != nil {
+		return time.Time{}, false
+	}
+	f := &boxFields{b: payload}
+	version := f.uint(1)
+	f.uint(3)
+	var created uint64
+	if version == 1 {
+		created = f.uint(8)
+	} else {
+		created = f.uint(4)
+	}
+	if f.short || created <= quickTimeEpochOffset {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(created-quickTimeEpochOffset), 0).UTC(), true
+}
+
+// Read the creation date, make and model from the keys and ilst boxes of a QuickTime meta box
+func readAppleKeys(r io.ReaderAt, meta isoBox, tags *videoTags) {
+	// In MP4 files meta is a full box with version and flags before its children
+	start := meta.start
+	if b, err := readISOBox(r, start, meta.end); err != nil || (b.typ != "hdlr" && b.typ != "keys" && b.typ != "ilst") {
+		start += 4
+	}
+	keysBox, err := findISOBox(r, start, meta.end, "keys")
+	if err != nil {
+		return
+	}
+	ilst, err := findISOBox(r, start, meta.end, "ilst")
+	if err != nil {
+		return
+	}
+	payload, err := readISOPayload(r, keysBox, maxHEIFBoxSize)
+	if err != nil {
+		return
+	}
+	f := &boxFields{b: payload}
+	f.uint(4) // version and flags
+	count := f.uint(4)
+	keys := make(map[uint64]string)
+	for i := uint64(1); i <= count && !f.short; i++ {
+		size := int(f.uint(4))
+		f.str(4) // namespace, mdta
+		if size < 8 {
+			break
+		}
+		keys[i] = f.str(size - 8)
+	}
+
+	for off := ilst.start; off+8 <= ilst.end; {
+		item, err := readISOBox(r, off, ilst.end)
+		if err != nil {
+			return
+		}
+		off = item.end
+		// Items are named by the 1-based index of their key
+		key := keys[uint64(binary.BigEndian.Uint32([]byte(item.typ)))]
+		var dst *string
+		switch key {
+		case "com.apple.quicktime.creationdate":
+			dst = &tags.appleCreationDate
+		case "com.apple.quicktime.make":
+			dst = &tags.make
+		case "com.apple.quicktime.model":
+			dst = &tags.model
+		default:
+			continue
+		}
+		data, err := findISOBox(r, item.start, item.end, "data")
+		if err != nil {
+			continue
+		}
+		value, err := readISOPayload(r, data, 4096)
+		if err != nil || len(value) < 8 {
+			continue
+		}
+		// Type indicator and locale precede the UTF-8 value
+		*dst = strings.TrimSpace(string(value[8:]))
+	}
+}
+
+// Read the make, model and capture time from the CMT1 and CMT2 boxes inside Canon's uuid box.
+// Both hold a TIFF-formatted EXIF block: CMT1 the IFD0 tags, CMT2 the Exif IFD tags.
+func readCanonUUID(r io.ReaderAt, b isoBox, tags *videoTags) {
+	id := make([]byte, len(canonUUID))
+	if b.end-b.start < int64(len(id)) {
+		return
+	}
+	if _, err := r.ReadAt(id, b.start); err != nil || !bytes.Equal(id, canonUUID) {
+		return
+	}
+	if cmt1, err := findISOBox(r, b.start+16, b.end, "CMT1"); err == nil {
+		if tiff, err := readISOPayload(r, cmt1, maxHEIFBoxSize); err == nil {
+			values, _ := readTIFFTags(bytes.NewReader(tiff), 0, int64(len(tiff)))
+			if tags.make == "" && tags.model == "" {
+				tags.make, tags.model = values[tagMake], values[tagModel]
+			}
+		}
+	}
+	if cmt2, err := findISOBox(r, b.start+16, b.end, "CMT2"); err == nil {
+		if tiff, err := readISOPayload(r, cmt2, maxHEIFBoxSize); err == nil {
+			values, _ := readTIFFTags(bytes.NewReader(tiff), 0, int64(len(tiff)))
+			tags.canonDateTimeOriginal, tags.canonOffset = values[tagDateTimeOriginal], values[tagOffsetTimeOriginal]
+			tags.canonSubSec = values[tagSubSecTimeOriginal]
+		}
+	}
+}
+
+// Read the first timestamp record of a Canon CTMD timed metadata track. The sample tables
+// locate its first sample, whose little-endian records have a 12 byte header; type 1 holds
+// the time as year, month, day, hour, minute, second and hundredths.
+func readCTMDTimestamp(r io.ReaderAt, trak isoBox) time.Time {
+	stbl, err := findISOBoxPath(r, trak, "mdia", "minf", "stbl")
+	if err != nil {
+		return time.Time{}
+	}
+	stsd, err := findISOBox(r, stbl.start, stbl.end, "stsd")
+	// stsd is a full box with an entry count before the sample entries
+	if err != nil || stsd.end-stsd.start < 16 {
+		return time.Time{}
+	}
+	if entry, err := readISOBox(r, stsd.start+8, stsd.end); err != nil || entry.typ != "CTMD" {
+		return time.Time{}
+	}
+
+	var offset uint64
+	if stco, err := findISOBox(r, stbl.start, stbl.end, "stco"); err == nil {
+		offset = readFirstTableEntry(r, stco, 4)
+	} else if co64, err := findISOBox(r, stbl.start, stbl.end, "co64"); err == nil {
+		offset = readFirstTableEntry(r, co64, 8)
+	}
+	stsz, err := findISOBox(r, stbl.start, stbl.end, "stsz")
+	if err != nil || offset == 0 {
+		return time.Time{}
+	}
+	head, err := readISOPayload(r, isoBox{typ: "stsz", start: stsz.start, end: min(stsz.start+16, stsz.end)}, 16)
+	if err != nil {
+		return time.Time{}
+	}
+	f := &boxFields{b: head}
+	f.uint(4)
+	size := f.uint(4)
+	if size == 0 {
+		// Every sample has its own size, the first one follows the sample count
+		f.uint(4)
+		size = f.uint(4)
+	}
+	if f.short || size == 0 || size > maxCTMDSampleSize {
+		return time.Time{}
+	}
+
+	sample := make([]byte, size)
+	if _, err := r.ReadAt(sample, int64(offset)); err != nil {
+		return time.Time{}
+	}
+	for len(sample) >= 12 {
+		recordSize := int(binary.LittleEndian.Uint32(sample))
+		recordType := binary.LittleEndian.Uint16(sample[4:])
+		if recordSize < 12 || recordSize > len(sample) {
+			break
+		}
+		if recordType == 1 && recordSize >= 12+12 {
+			d := sample[12+4:]
+			year := int(binary.LittleEndian.Uint16(d))
+			t := time.Date(year, time.Month(d[2]), int(d[3]), int(d[4]), int(d[5]), int(d[6]), int(d[7])*int(10*time.Millisecond), time.UTC)
+			if year > 1970 && t.Month() == time.Month(d[2]) && t.Day() == int(d[3]) {
+				return t
+			}
+		}
+		sample = sample[recordSize:]
+	}
+	return time.Time{}
+}
+
+// Follow a path of nested boxes below parent
+func findISOBoxPath(r io.ReaderAt, parent isoBox, path ...string) (isoBox, error) {
+	b := parent
+	for _, typ := range path {
+		var err error
+		if b, err = findISOBox(r, b.start, b.end, typ); err != nil {
+			return isoBox{}, err
+		}
+	}
+	return b, nil
+}
+
+// Read the first entry of a chunk offset table (stco or co64)
+func readFirstTableEntry(r io.ReaderAt, b isoBox, size int) uint64 {
+	head, err := readISOPayload(r, isoBox{typ: b.typ, start: b.start, end: min(b.start+8+int64(size), b.end)}, 16)
+	if err != nil {
+		return 0
+	}
+	f := &boxFields{b: head}
+	f.uint(4)
+	if f.uint(4) == 0 {
+		return 0
+	}
+	return f.uint(size)
+}
+
+// videoResolver reads the creation time of QuickTime and MP4 videos
+type videoResolver struct{}
+
+func (videoResolver) Name() string { return TimeSourceVideo }
+
+func (videoResolver) Resolve(ctx context.Context, f *SourceFile) (Resolution, error) {
+	tags, err := f.videoTags(ctx)
+	if err != nil {
+		return Resolution{}, err
+	}
+	if tags.appleCreationDate != "" {
+		for _, layout := range []string{"2006-01-02T15:04:05-0700", time.RFC3339} {
+			if t, err := time.Parse(layout, tags.appleCreationDate); err == nil {
+				return Resolution{Timestamp: t, Confidence: 1, Reason: "QuickTime com.apple.quicktime.creationdate"}, nil
+			}
+		}
+	}
+	if tags.canonDateTimeOriginal != "" {
+		layout := "2006:01:02 15:04:05"
+		subSec, _ := parseSubSec(tags.canonSubSec)
+		if tags.canonOffset != "" {
+			if t, err := time.Parse(layout+"-07:00", tags.canonDateTimeOriginal+tags.canonOffset); err == nil {
+				return Resolution{Timestamp: t.Add(subSec), Confidence: 1, Reason: "Canon CMT2 DateTimeOriginal with OffsetTimeOriginal"}, nil
+			}
+		}
+		if t, err := time.ParseInLocation(layout, tags.canonDateTimeOriginal, f.location()); err == nil {
+			return Resolution{Timestamp: t.Add(subSec), Confidence: 0.9, Reason: "Canon CMT2 DateTimeOriginal, " + f.readAs()}, nil
+		}
+	}
+	if !tags.canonCTMD.IsZero() {
+		c := tags.canonCTMD
+		t := time.Date(c.Year(), c.Month(), c.Day(), c.Hour(), c.Minute(), c.Second(), c.Nanosecond(), f.location())
+		return Resolution{Timestamp: t, Confidence: 0.9, Reason: "Canon CTMD timestamp, " + f.readAs()}, nil
+	}
+	if !tags.created.IsZero() {
+		// Some cameras store local time instead of UTC here. The instant is shown in the zone
+		// naive stills are read in, so a clip lands in the same date folder as its photos.
+		return Resolution{Timestamp: tags.created.In(f.location()), Confidence: 0.7, Reason: fmt.Sprintf("%s creation_time (UTC)", tags.createdBox)}, nil
+	}
+	return Resolution{}, nil
+}
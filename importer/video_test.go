@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Seconds since 1904 of t, as stored in mvhd and tkhd
+func quickTime(t time.Time) uint32 {
+	return uint32(t.Unix() + quickTimeEpochOffset)
+}
+
+func mvhdBox(created uint32) []byte {
+	return isoBoxBytes("mvhd", []byte{0, 0, 0, 0}, binary.BigEndian.AppendUint32(nil, created), make([]byte, 88))
+}
+
+// appleMetaBox builds a QuickTime meta box with keys and ilst entries
+func appleMetaBox(entries map[string]string) []byte {
+	var keys, ilst [][]byte
+	i := uint32(0)
+	for _, key := range []string{"com.apple.quicktime.make", "com.apple.quicktime.model", "com.apple.quicktime.creationdate"} {
+		value, ok := entries[key]
+		if !ok {
+			continue
+		}
+		i++
+		keys = append(keys, binary.BigEndian.AppendUint32(nil, uint32(8+len(key))), []byte("mdta"+key))
+		data := isoBoxBytes("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(value))
+		ilst = append(ilst, isoBoxBytes(string(binary.BigEndian.AppendUint32(nil, i)), data))
+	}
+	keysPayload := append([]byte{0, 0, 0, 0}, binary.BigEndian.AppendUint32(nil, i)...)
+	return isoBoxBytes("meta",
+		isoBoxBytes("hdlr", make([]byte, 24)),
+		isoBoxBytes("keys", keysPayload, bytes.Join(keys, nil)),
+		isoBoxBytes("ilst", ilst...),
+	)
+}
+
+func writeVideo(t *testing.T, name string, moovChildren ...[]byte) (string, os.FileInfo) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	data := bytes.Join([][]byte{
+		isoBoxBytes("ftyp", []byte("qt  "), make([]byte, 4), []byte("qt  ")),
+		isoBoxBytes("mdat", []byte("frames")),
+		isoBoxBytes("moov", moovChildren...),
+	}, nil)
+	mustWriteBytes(t, path, data)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	return path, fi
+}
+
+func resolveVideo(t *testing.T, path string, fi os.FileInfo) FileMeta {
+	t.Helper()
+	chain, _ := ParseTimeSources("video")
+	meta, err := resolveTimestamp(context.Background(), timestampConfig{resolvers: chain}, path, fi, func(string, ...any) {})
+	if err != nil {
+		t.Fatalf("resolveTimestamp returned error: %v", err)
+	}
+	return meta
+}
+
+func TestVideoResolverPrefersAppleCreationDate(t *testing.T) {
+	created := time.Date(2024, 6, 1, 21, 22, 33, 0, time.UTC)
+	path, fi := writeVideo(t, "IMG_0043.MOV",
+		mvhdBox(quickTime(created)),
+		appleMetaBox(map[string]string{
+			"com.apple.quicktime.make":         "Apple",
+			"com.apple.quicktime.model":        "iPhone 15",
+			"com.apple.quicktime.creationdate": "2024-06-01T14:22:33-0700",
+		}),
+	)
+	meta := resolveVideo(t, path, fi)
+	if meta.Source != TimeSourceVideo || meta.Confidence != 1 || meta.Timestamp.Format(time.RFC3339) != "2024-06-01T14:22:33-07:00" {
+		t.Fatalf("expected the Apple creation date, got: %+v", meta)
+	}
+	if meta.Make != "Apple" || meta.Model != "iPhone 15" {
+		t.Fatalf("expected the camera from the QuickTime keys, got: %+v", meta)
+	}
+
+	// The default chain dates videos without EXIF the same way
+	meta, err := resolveTimestamp(context.Background(), timestampConfig{}, path, fi, func(string, ...any) {})
+	if err != nil || meta.Source != TimeSourceVideo || meta.Confidence != 1 {
+		t.Fatalf("expected the default chain to use the video resolver, got: %+v (%v)", meta, err)
+	}
+}
+
+func TestVideoResolverReadsMovieHeaderInUTC(t *testing.T) {
+	created := time.Date(2024, 6, 1, 21, 22, 33, 0, time.UTC)
+	path, fi := writeVideo(t, "clip.mp4", mvhdBox(quickTime(created)))
+	meta := resolveVideo(t, path, fi)
+	if meta.Source != TimeSourceVideo || !meta.Timestamp.Equal(created) || meta.Reason != "mvhd creation_time (UTC)" {
+		t.Fatalf("expected the mvhd creation time, got: %+v", meta)
+	}
+
+	// An unset creation_time falls through to the track header
+	tkhd := isoBoxBytes("tkhd", []byte{0, 0, 0, 0}, binary.BigEndian.AppendUint32(nil, quickTime(created)), make([]byte, 76))
+	path, fi = writeVideo(t, "clip2.mp4", mvhdBox(0), isoBoxBytes("trak", tkhd))
+	if meta := resolveVideo(t, path, fi); !meta.Timestamp.Equal(created) || meta.Reason != "tkhd creation_time (UTC)" {
+		t.Fatalf("expected the tkhd creation time, got: %+v", meta)
+	}
+}
+
+func TestImportFilesVideosWithStillsOfTheSameMinute(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	root := t.TempDir()
+	from := filepath.Join(root, "from")
+	if err := os.MkdirAll(from, 0o755); err != nil {
+		t.Fatalf("mkdir from failed: %v", err)
+	}
+	// Just after midnight in Berlin, still the previous day in UTC
+	mustWriteBytes(t, filepath.Join(from, "IMG_0001.JPG"), buildJPEG(buildTIFF(nil, []tiffEntry{asciiEntry(0x9003, "2024:06:02 00:30:00")}, nil)))
+	video, _ := writeVideo(t, "MVI_0002.MP4", mvhdBox(quickTime(time.Date(2024, 6, 2, 0, 31, 0, 0, berlin))))
+	mustWriteBytes(t, filepath.Join(from, "MVI_0002.MP4"), []byte(readFileString(t, video)))
+
+	to := filepath.Join(root, "to")
+	cfg := Options{
+		From:        from,
+		To:          to,
+		End:         time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		MaxWorkers:  1,
+		Layout:      "{date}",
+		TimeOptions: TimeOptions{TimeZone: berlin},
+	}
+	var out bytes.Buffer
+	if _, err := runImport(context.Background(), cfg, &out, nil); err != nil {
+		t.Fatalf("runImport returned error: %v\noutput:\n%s", err, out.String())
+	}
+	for _, name := range []string{"IMG_0001.JPG", "MVI_0002.MP4"} {
+		if _, err := os.Stat(filepath.Join(to, "2024-06-02", name)); err != nil {
+			t.Fatalf("expected %s in the Berlin date folder: %v\noutput:\n%s", name, err, out.String())
+		}
+	}
+}
+
+func TestVideoResolverReadsCanonMetadata(t *testing.T) {
+	cmt1 := buildTIFF([]tiffEntry{asciiEntry(0x010f, "Canon"), asciiEntry(0x0110, "Canon EOS R6")}, nil, nil)
+	cmt2 := buildTIFF([]tiffEntry{asciiEntry(0x9003, "2024:06:01 14:22:33"), asciiEntry(0x9011, "+02:00")}, nil, nil)
+	uuid := isoBoxBytes("uuid", canonUUID, isoBoxBytes("CNCV", []byte("CanonCRM0001")), isoBoxBytes("CMT1", cmt1), isoBoxBytes("CMT2", cmt2))
+	path, fi := writeVideo(t, "MVI_0001.MP4", mvhdBox(quickTime(time.Date(2024, 6, 1, 14, 22, 33, 0, time.UTC))), uuid)
+	meta := resolveVideo(t, path, fi)
+	if meta.Timestamp.Format(time.RFC3339) != "2024-06-01T14:22:33+02:00" || meta.Reason != "Canon CMT2 DateTimeOriginal with OffsetTimeOriginal" {
+		t.Fatalf("expected the Canon CMT2 time, got: %+v", meta)
+	}
+	if meta.Make != "Canon" || meta.Model != "Canon EOS R6" {
+		t.Fatalf("expected the camera from CMT1, got: %+v", meta)
+	}
+}
+
+func TestReadCTMDTimestamp(t *testing.T) {
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	// A CTMD sample with an unrelated record followed by a timestamp record
+	record := func(typ uint16, data []byte) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, uint32(12+len(data)))
+		b = binary.LittleEndian.AppendUint16(b, typ)
+		return append(append(b, make([]byte, 6)...), data...)
+	}
+	stamp := append(make([]byte, 4), binary.LittleEndian.AppendUint16(nil, 2024)...)
+	stamp = append(stamp, 6, 1, 14, 22, 33, 50, 0, 0, 0, 0)
+	sample := append(record(7, []byte("lens")), record(1, stamp)...)
+
+	prefix := isoBoxBytes("ftyp", []byte("crx "), make([]byte, 4))
+	sampleOffset := uint32(len(prefix) + 8)
+	stbl := isoBoxBytes("stbl",
+		isoBoxBytes("stsd", []byte{0, 0, 0, 0}, u32(1), isoBoxBytes("CTMD", make([]byte, 8))),
+		isoBoxBytes("stsz", []byte{0, 0, 0, 0}, u32(uint32(len(sample))), u32(1)),
+		isoBoxBytes("stco", []byte{0, 0, 0, 0}, u32(1), u32(sampleOffset)),
+	)
+	trak := isoBoxBytes("trak", isoBoxBytes("mdia", isoBoxBytes("minf", stbl)))
+	file := bytes.Join([][]byte{prefix, isoBoxBytes("mdat", sample), isoBoxBytes("moov", trak)}, nil)
+
+	tags := readVideoTags(bytes.NewReader(file), int64(len(file)))
+	want := time.Date(2024, 6, 1, 14, 22, 33, 500*int(time.Millisecond), time.UTC)
+	if !tags.canonCTMD.Equal(want) {
+		t.Fatalf("expected CTMD timestamp %v, got %v", want, tags.canonCTMD)
+	}
+}
+
+func TestVideoResolverIgnoresOtherFiles(t *testing.T) {
+	jpeg := buildJPEG(buildTIFF(nil, nil, nil))
+	if tags := readVideoTags(bytes.NewReader(jpeg), int64(len(jpeg))); *tags != (videoTags{}) {
+		t.Fatalf("expected no video tags for a JPEG, got: %+v", tags)
+	}
+}
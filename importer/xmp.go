@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// How far into a file an embedded XMP packet is searched for
+const xmpScanLimit = 4 << 20
+
+// XMP properties holding the capture time, most specific first
+var xmpDateProperties = []string{"exif:DateTimeOriginal", "photoshop:DateCreated", "xmp:CreateDate"}
+
+// Match a property written either as an attribute (exif:DateTimeOriginal="...") or as an
+// element (<exif:DateTimeOriginal>...</exif:DateTimeOriginal>)
+var xmpDatePatterns = func() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(xmpDateProperties))
+	for i, property := range xmpDateProperties {
+		patterns[i] = regexp.MustCompile(regexp.QuoteMeta(property) + `(?:\s*=\s*["']([^"']+)["']|\s*>\s*([^<\s]+)\s*<)`)
+	}
+	return patterns
+}()
+
+// Layouts of XMP dates, which are ISO 8601 with optional seconds, fraction and zone
+var xmpDateLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// xmpResolver reads the capture time from an XMP sidecar (photo.xmp or photo.jpg.xmp) or from
+// an XMP packet embedded in the file
+type xmpResolver struct{}
+
+func (xmpResolver) Name() string { return TimeSourceXMP }
+
+func (xmpResolver) Resolve(ctx context.Context, f *SourceFile) (Resolution, error) {
+	base := strings.TrimSuffix(f.Path, filepath.Ext(f.Path))
+	for _, sidecar := range []string{base + ".xmp", base + ".XMP", f.Path + ".xmp", f.Path + ".XMP"} {
+		packet, err := os.ReadFile(sidecar)
+		if err != nil {
+			continue
+		}
+		t, property, zoned, ok := parseXMPDate(packet, f.location())
+		if !ok {
+			continue
+		}
+		return xmpResolution(f, t, zoned, fmt.Sprintf("XMP %s in sidecar %s", property, filepath.Base(sidecar))), nil
+	}
+
+	file, err := f.open()
+	if err != nil {
+		return Resolution{}, err
+	}
+	defer file.Close()
+	head, err := io.ReadAll(io.LimitReader(contextReader{ctx, file}, xmpScanLimit))
+	if err != nil {
+		return Resolution{}, err
+	}
+	start := bytes.Index(head, []byte("<x:xmpmeta"))
+	if start < 0 {
+		return Resolution{}, nil
+	}
+	packet := head[start:]
+	if end := bytes.Index(packet, []byte("</x:xmpmeta>")); end >= 0 {
+		packet = packet[:end]
+	}
+	t, property, zoned, ok := parseXMPDate(packet, f.location())
+	if !ok {
+		return Resolution{}, nil
+	}
+	return xmpResolution(f, t, zoned, "embedded XMP "+property), nil
+}
+
+// XMP dates without a zone are read in the file's location and trusted a little less
+func xmpResolution(f *SourceFile, t time.Time, zoned bool, reason string) Resolution {
+	if !zoned {
+		return Resolution{Timestamp: t, Confidence: 0.8, Reason: reason + ", " + f.readAs()}
+	}
+	return Resolution{Timestamp: t, Confidence: 0.95, Reason: reason}
+}
+
+// Find the first of xmpDateProperties with a readable date in an XMP packet
+func parseXMPDate(packet []byte, loc *time.Location) (t time.Time, property string, zoned bool, ok bool) {
+	for i, property := range xmpDateProperties {
+		m := xmpDatePatterns[i].FindSubmatch(packet)
+		if m == nil {
+			continue
+		}
+		value := string(m[1])
+		if value == "" {
+			value = string(m[2])
+		}
+		if t, zoned, ok := parseXMPTime(value, loc); ok {
+			return t, property, zoned, true
+		}
+	}
+	return time.Time{}, "", false, false
+}
+
+// Parse an ISO 8601 XMP date. Dates without a zone are read in loc.
+func parseXMPTime(value string, loc *time.Location) (t time.Time, zoned bool, ok bool) {
+	for i, layout := range xmpDateLayouts {
+		zoned := i < 2
+		var err error
+		if zoned {
+			t, err = time.Parse(layout, value)
+		} else {
+			t, err = time.ParseInLocation(layout, value, loc)
+		}
+		if err == nil {
+			return t, zoned, true
+		}
+	}
+	return time.Time{}, false, false
+}